@@ -0,0 +1,67 @@
+package filter
+
+import (
+	"os"
+	"strings"
+
+	"alertbot/command"
+)
+
+const accessStateFile = "access.json"
+
+// loadAccess seed the allow-list from ACCESS and restore the granted roles
+func (bf *BinanceFilter) loadAccess() error {
+	if err := bf.access.Seed(os.Getenv("ACCESS")); err != nil {
+		return err
+	}
+
+	granted := map[string]command.Role{}
+	if err := loadState(accessStateFile, &granted); err != nil {
+		return err
+	}
+	for id, role := range granted {
+		bf.access.Grant(id, role)
+	}
+
+	return nil
+}
+
+// Access list the allowed users and chats
+func (bf *BinanceFilter) Access(c *command.Context) {
+	c.Reply(bf.access.String())
+}
+
+// AccessGrant give a role to a user or a chat, e.g. "telegram:123456 operator"
+func (bf *BinanceFilter) AccessGrant(c *command.Context) {
+	role, _ := command.ParseRole(c.String("role"))
+	bf.access.Grant(c.String("id"), role)
+	bf.saveAccess(c)
+}
+
+// AccessRevoke the granted role of a user or a chat
+func (bf *BinanceFilter) AccessRevoke(c *command.Context) {
+	if _, found := bf.access.Granted()[c.String("id")]; !found {
+		c.Invalid("id", "not found")
+		return
+	}
+
+	bf.access.Grant(c.String("id"), command.None)
+	bf.saveAccess(c)
+}
+
+func (bf *BinanceFilter) saveAccess(c *command.Context) {
+	if err := saveState(accessStateFile, bf.access.Granted()); err != nil {
+		c.Reply(err.Error())
+		return
+	}
+
+	c.Reply(bf.access.String())
+}
+
+// checkAccessID a "<messenger>:<id>" identifier
+func checkAccessID(id string) error {
+	if messenger, user, found := strings.Cut(id, ":"); !found || messenger == "" || user == "" {
+		return errWrongAccessID
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package filter
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"alertbot/command"
+)
+
+const (
+	auditStateFile = "audit.json"
+	maxChanges     = 500
+	ignoredState   = "ignored"
+)
+
+// change of the configuration, Old and New are the states before and after it
+type change struct {
+	ID     int
+	Time   int64 // milliseconds
+	User   string
+	Action string // set, ignore, unignore, mute, unmute, filter, clear, profile, restart, undo
+	Target string `json:",omitempty"`
+	Old    string `json:",omitempty"`
+	New    string `json:",omitempty"`
+	Undone bool   `json:",omitempty"`
+}
+
+// revertible changes restore their Old state on /undo
+var revertible = map[string]struct{}{
+	"set": {}, "ignore": {}, "unignore": {}, "mute": {}, "unmute": {}, "filter": {}, "clear": {}, "profile": {},
+}
+
+func (ch change) format(loc *time.Location) string {
+	ret := fmt.Sprintf("#%d %s %s %s", ch.ID, time.UnixMilli(ch.Time).In(loc).Format("15:04:05 2006-01-02"), ch.User, ch.Action)
+	if ch.Target != "" {
+		ret += " " + ch.Target
+	}
+	if _, found := revertible[ch.Action]; found {
+		ret += fmt.Sprintf(": %s → %s", state(ch.Old), state(ch.New))
+	}
+	if ch.Undone {
+		ret += " (undone)"
+	}
+	return ret
+}
+
+func state(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+// audit keeps the persisted changes of the configuration, the oldest are dropped
+type audit struct {
+	mu      sync.Mutex
+	Changes []change
+	NextID  int
+}
+
+func (a *audit) load() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return loadState(auditStateFile, a)
+}
+
+func (a *audit) add(ch change) (change, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.NextID++
+	ch.ID = a.NextID
+	a.Changes = append(a.Changes, ch)
+	if len(a.Changes) > maxChanges {
+		a.Changes = a.Changes[len(a.Changes)-maxChanges:]
+	}
+
+	return ch, saveState(auditStateFile, a)
+}
+
+// last revertible change not undone yet
+func (a *audit) last() (change, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i := len(a.Changes) - 1; i >= 0; i-- {
+		ch := a.Changes[i]
+		if _, found := revertible[ch.Action]; found && !ch.Undone {
+			return ch, true
+		}
+	}
+
+	return change{}, false
+}
+
+func (a *audit) undone(id int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i := range a.Changes {
+		if a.Changes[i].ID == id {
+			a.Changes[i].Undone = true
+		}
+	}
+
+	return saveState(auditStateFile, a)
+}
+
+// list the count last changes, the latest first
+func (a *audit) list(count int) []change {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ret := []change{}
+	for i := len(a.Changes) - 1; i >= 0 && len(ret) < count; i-- {
+		ret = append(ret, a.Changes[i])
+	}
+
+	return ret
+}
+
+// recordChange of a command in the audit log
+func (bf *BinanceFilter) recordChange(user string, action string, target string, before string, after string) {
+	ch, err := bf.audit.add(change{Time: time.Now().UnixMilli(), User: user, Action: action, Target: target, Old: before, New: after})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	log.Println("Audit " + ch.format(bf.localTime))
+}
+
+func (bf *BinanceFilter) ignoreState(symbol string) string {
+	if bf.ignored.has(symbol) {
+		return ignoredState
+	}
+	return ""
+}
+
+// muted channels, e.g. "FSELL UP", for the audit
+func (bf *BinanceFilter) muted() string {
+	ret := []string{}
+	for channel, enabled := range bf.channel {
+		if !enabled.Load() {
+			ret = append(ret, channel)
+		}
+	}
+	sort.Strings(ret)
+
+	return strings.Join(ret, " ")
+}
+
+// revert a change to its Old state
+func (bf *BinanceFilter) revert(ch change) error {
+	switch ch.Action {
+	case "set":
+		value, err := strconv.ParseFloat(ch.Old, 64)
+		if err != nil {
+			return err
+		}
+		_, err = bf.setConfiguration(ch.Target, value)
+		return err
+	case "ignore", "unignore":
+		if ch.Old == ignoredState {
+			bf.ignored.add(ch.Target)
+		} else {
+			bf.ignored.remove(ch.Target)
+		}
+		bf.ignores.set(ch.Target, 0)
+	case "mute", "unmute":
+		bf.setMuted(strings.Fields(ch.Old))
+	case "filter", "clear":
+		return bf.watchlists.setRestrictions(ch.Old)
+	case "profile":
+		p, err := parseProfile(ch.Old)
+		if err != nil {
+			return err
+		}
+		return bf.applyProfile(p)
+	}
+
+	return nil
+}
+
+// Audit list the last changes of the configuration, 10 by default
+func (bf *BinanceFilter) Audit(c *command.Context) {
+	count := 10
+	if c.Has("count") {
+		count = int(c.Int("count"))
+	}
+
+	lines := []string{}
+	for _, ch := range bf.audit.list(count) {
+		lines = append(lines, ch.format(bf.localTime))
+	}
+
+	if len(lines) == 0 {
+		c.Reply("no change")
+		return
+	}
+
+	c.Reply(strings.Join(lines, "\n"))
+}
+
+// Undo revert the last change not undone yet
+func (bf *BinanceFilter) Undo(c *command.Context) {
+	ch, found := bf.audit.last()
+	if !found {
+		c.Reply("nothing to undo")
+		return
+	}
+
+	if err := bf.revert(ch); err != nil {
+		c.Reply(err.Error())
+		return
+	}
+
+	if err := bf.audit.undone(ch.ID); err != nil {
+		log.Println(err)
+	}
+	bf.recordChange(c.Request.User, "undo", fmt.Sprintf("#%d", ch.ID), "", "")
+
+	ch.Undone = true
+	c.Reply(ch.format(bf.localTime))
+}
@@ -0,0 +1,171 @@
+package filter
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2"
+	"github.com/adshao/go-binance/v2/delivery"
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+const (
+	backfillMinutes  = 60
+	backfillWorkers  = 4
+	backfillRate     = 10 // requests per second
+	backfillInterval = "1m"
+)
+
+// newClient for the spot REST API, BINANCE_API_URL overrides the endpoint
+func newClient() *binance.Client {
+	client := binance.NewClient(os.Getenv("BINANCE_API_KEY"), os.Getenv("BINANCE_SECRET_KEY"))
+	if url := os.Getenv("BINANCE_API_URL"); url != "" {
+		client.BaseURL = url
+	}
+
+	return client
+}
+
+// newFuturesClient for the futures REST API, BINANCE_FUTURES_API_URL overrides the endpoint
+func newFuturesClient() *futures.Client {
+	client := binance.NewFuturesClient(os.Getenv("BINANCE_API_KEY"), os.Getenv("BINANCE_SECRET_KEY"))
+	if url := os.Getenv("BINANCE_FUTURES_API_URL"); url != "" {
+		client.BaseURL = url
+	}
+
+	return client
+}
+
+// newDeliveryClient for the COIN-M futures REST API, BINANCE_DELIVERY_API_URL overrides the endpoint
+func newDeliveryClient() *delivery.Client {
+	client := binance.NewDeliveryClient(os.Getenv("BINANCE_API_KEY"), os.Getenv("BINANCE_SECRET_KEY"))
+	if url := os.Getenv("BINANCE_DELIVERY_API_URL"); url != "" {
+		client.BaseURL = url
+	}
+
+	return client
+}
+
+// backfill fetch 1m klines of the symbols whose history is shorter than an hour
+func (bf *BinanceFilter) backfill() {
+	if !bf.backfilling.CompareAndSwap(false, true) {
+		return
+	}
+	defer bf.backfilling.Store(false)
+
+	start := time.Now()
+	client := newClient()
+
+	// the rolling 24h volumes of the klines are estimated from the current ones
+	stats, err := client.NewListPriceChangeStatsService().Do(context.Background())
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	from := start.UnixMilli() - backfillMinutes*milliInMin
+	symbols := make(chan *binance.PriceChangeStats)
+	go func() {
+		defer close(symbols)
+		for _, stat := range stats {
+			history, found := bf.market[stat.Symbol]
+			if !found || history.covers(from) {
+				continue
+			}
+
+			quoteVolume, err := strconv.ParseFloat(stat.QuoteVolume, 64)
+			if err != nil {
+				continue
+			}
+			if usdVolume := bf.usd(stat.Symbol, quoteVolume); usdVolume < bf.threshold(stat.Symbol, "minvolume", bf.minQuoteThreshold) ||
+				usdVolume > bf.threshold(stat.Symbol, "maxvolume", bf.maxQuoteThreshold) {
+				continue
+			}
+
+			symbols <- stat
+		}
+	}()
+
+	limiter := time.NewTicker(time.Second / backfillRate)
+	defer limiter.Stop()
+
+	var wg sync.WaitGroup
+	var count, failed int
+	var mu sync.Mutex
+	for i := 0; i < backfillWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for stat := range symbols {
+				<-limiter.C
+				candles, err := fetchCandles(client, stat)
+
+				mu.Lock()
+				count++
+				if err != nil {
+					failed++
+					log.Printf("Backfill %s: %v\n", stat.Symbol, err)
+				}
+				mu.Unlock()
+
+				if err == nil {
+					bf.market[stat.Symbol].backfill(candles, bf.retention.Load())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	log.Printf("Backfilled %d symbols, %d failed, took %s\n", count-failed, failed, time.Since(start))
+}
+
+// fetchCandles of the last minutes with the rolling 24h volumes derived from stat
+func fetchCandles(client *binance.Client, stat *binance.PriceChangeStats) ([]candle, error) {
+	klines, err := client.NewKlinesService().Symbol(stat.Symbol).Interval(backfillInterval).Limit(backfillMinutes).Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	baseVolume, err := strconv.ParseFloat(stat.Volume, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	quoteVolume, err := strconv.ParseFloat(stat.QuoteVolume, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	// the current kline is not closed yet
+	if len(klines) > 0 && klines[len(klines)-1].CloseTime >= time.Now().UnixMilli() {
+		klines = klines[:len(klines)-1]
+	}
+
+	candles := make([]candle, len(klines))
+	for i := len(klines) - 1; i >= 0; i-- {
+		k := klines[i]
+		c := candle{Time: k.OpenTime, BaseVolume: baseVolume, QuoteVolume: quoteVolume}
+		for _, v := range []struct {
+			s string
+			f *float64
+		}{{k.Open, &c.Open}, {k.Low, &c.Low}, {k.High, &c.High}, {k.Close, &c.Close}} {
+			if *v.f, err = strconv.ParseFloat(v.s, 64); err != nil {
+				return nil, err
+			}
+		}
+		candles[i] = c
+
+		if volume, err := strconv.ParseFloat(k.Volume, 64); err == nil {
+			baseVolume -= volume
+		}
+		if volume, err := strconv.ParseFloat(k.QuoteAssetVolume, 64); err == nil {
+			quoteVolume -= volume
+		}
+	}
+
+	return candles, nil
+}
@@ -0,0 +1,172 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubExchange serves the 24h stats of the symbols and the last 1m klines up to the current one,
+// each kline trading 10 base and 100 quote
+type stubExchange struct {
+	mu     sync.Mutex
+	stats  []map[string]interface{}
+	klines map[string]int // requests by symbol
+	now    int64
+}
+
+func klinePrice(t int64) float64 { return float64(10 + t/milliInMin%50) }
+
+func (s *stubExchange) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/api/v3/ticker/24hr":
+		json.NewEncoder(w).Encode(s.stats)
+	case "/api/v3/klines":
+		s.klines[r.URL.Query().Get("symbol")]++
+		limit, _ := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+
+		current := s.now - s.now%milliInMin
+		klines := [][]interface{}{}
+		for t := current - (limit-1)*milliInMin; t <= current; t += milliInMin {
+			p := klinePrice(t)
+			klines = append(klines, []interface{}{t, fmt.Sprint(p), fmt.Sprint(p + 2), fmt.Sprint(p - 1), fmt.Sprint(p + 1),
+				"10", t + milliInMin - 1, "100", 1, "0", "0", "0"})
+		}
+		json.NewEncoder(w).Encode(klines)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveStub exchange for the spot REST API
+func serveStub(t *testing.T, stats ...map[string]interface{}) *stubExchange {
+	stub := &stubExchange{stats: stats, klines: make(map[string]int), now: time.Now().UnixMilli()}
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	t.Setenv("BINANCE_API_URL", server.URL)
+
+	return stub
+}
+
+func TestBackfill(t *testing.T) {
+	bf, _ := newTestFilter(t, "SOLUSDT", "EOSUSDT")
+	stub := serveStub(t,
+		map[string]interface{}{"symbol": "SOLUSDT", "volume": "1000", "quoteVolume": "20000000"},
+		map[string]interface{}{"symbol": "EOSUSDT", "volume": "1000", "quoteVolume": "100"},      // below minvolume
+		map[string]interface{}{"symbol": "XYZUSDT", "volume": "1000", "quoteVolume": "20000000"}, // not tracked
+	)
+
+	bf.backfill()
+
+	if stub.klines["SOLUSDT"] != 1 || stub.klines["EOSUSDT"] != 0 || stub.klines["XYZUSDT"] != 0 {
+		t.Errorf("klines requested %v, want SOLUSDT only", stub.klines)
+	}
+
+	// the current kline is dropped and the rolling volumes go back by the kline volumes
+	h := bf.market["SOLUSDT"]
+	if h.candles.Len() != backfillMinutes-1 {
+		t.Fatalf("%d candles, want %d", h.candles.Len(), backfillMinutes-1)
+	}
+	current := stub.now - stub.now%milliInMin
+	for i := 0; i < h.candles.Len(); i++ {
+		back := int64(h.candles.Len() - i) // minutes before the current kline
+		tm := current - back*milliInMin
+		p := klinePrice(tm)
+		want := candle{Time: tm, Open: p, High: p + 2, Low: p - 1, Close: p + 1,
+			BaseVolume: 1000 - 10*float64(back-1), QuoteVolume: 20_000_000 - 100*float64(back-1)}
+		if got := h.candles.At(i); got != want {
+			t.Errorf("candle %d = %+v, want %+v", i, got, want)
+		}
+	}
+
+	// the closes fill the last hour
+	if last := h.last(); last.Price != klinePrice(current-milliInMin)+1 || last.QuoteVolume != 20_000_000 {
+		t.Errorf("last sample = %+v, want the close of the last kline", last)
+	}
+	if bf.market["EOSUSDT"].last().Time != 0 {
+		t.Error("EOSUSDT backfilled below minvolume")
+	}
+}
+
+func TestBackfillSinceSnapshot(t *testing.T) {
+	stub := serveStub(t, map[string]interface{}{"symbol": "SOLUSDT", "volume": "1000", "quoteVolume": "20000000"})
+	now := stub.now
+	snapshotTime := now - 20*milliInMin
+
+	// a history of 2 hours until the snapshot
+	before, _ := newTestFilter(t, "SOLUSDT")
+	for tm := snapshotTime - 2*milliInHour; tm <= snapshotTime; tm += 10 * milliInSec {
+		before.market["SOLUSDT"].push(marketdata{Price: 100, BaseVolume: 1000, QuoteVolume: 20_000_000, Time: tm}, defaultRetention)
+	}
+	snap := before.market["SOLUSDT"].snapshot(snapshotTail)
+	if first := snap.Recent[0].Time; first < snapshotTime-snapshotTail {
+		t.Errorf("snapshot samples since %d, want the tail since %d", first, snapshotTime-snapshotTail)
+	}
+
+	// restarted with the snapshot, the live stream already pushed the last 2 minutes
+	bf, _ := newTestFilter(t, "SOLUSDT")
+	h := bf.market["SOLUSDT"]
+	h.restore(snap)
+	for tm := now - 2*milliInMin; tm <= now; tm += 10 * milliInSec {
+		h.push(marketdata{Price: 200, BaseVolume: 1000, QuoteVolume: 20_000_000, Time: tm}, defaultRetention)
+	}
+
+	bf.backfill()
+
+	if stub.klines["SOLUSDT"] != 1 {
+		t.Fatalf("klines requested %v, want SOLUSDT", stub.klines)
+	}
+
+	// one candle per minute from the restored ones through the gap up to the live ones
+	first := h.candles.Front().Time
+	for i := 1; i < h.candles.Len(); i++ {
+		if got, want := h.candles.At(i).Time, first+int64(i)*milliInMin; got != want {
+			t.Fatalf("candle %d at %d, want %d", i, got, want)
+		}
+	}
+	if last := h.candles.Back().Time; last != now-now%milliInMin-milliInMin {
+		t.Errorf("last candle at %d, want the minute before the current one", last)
+	}
+
+	// the gap since the snapshot is priced by the klines
+	gap := snapshotTime + 5*milliInMin
+	if got, _ := h.priceAt(gap); got != klinePrice(gap-gap%milliInMin-milliInMin)+1 {
+		t.Errorf("priceAt in the gap = %v, want the close of the previous kline", got)
+	}
+	if _, _, _, ok := h.window(now - 10*milliInMin); !ok {
+		t.Error("no window over the gap")
+	}
+}
+
+func TestBackfillFreshSnapshot(t *testing.T) {
+	stub := serveStub(t, map[string]interface{}{"symbol": "SOLUSDT", "volume": "1000", "quoteVolume": "20000000"})
+	now := stub.now
+
+	// a snapshot of 2 hours taken just before the restart
+	before, _ := newTestFilter(t, "SOLUSDT")
+	for tm := now - 2*milliInHour; tm <= now-30*milliInSec; tm += 10 * milliInSec {
+		before.market["SOLUSDT"].push(marketdata{Price: 100, BaseVolume: 1000, QuoteVolume: 20_000_000, Time: tm}, defaultRetention)
+	}
+
+	bf, _ := newTestFilter(t, "SOLUSDT")
+	h := bf.market["SOLUSDT"]
+	h.restore(before.market["SOLUSDT"].snapshot(snapshotTail))
+	for tm := now - 20*milliInSec; tm <= now; tm += 10 * milliInSec {
+		h.push(marketdata{Price: 200, BaseVolume: 1000, QuoteVolume: 20_000_000, Time: tm}, defaultRetention)
+	}
+
+	// the restored candles cover the backfill without a missing minute
+	bf.backfill()
+
+	if len(stub.klines) != 0 {
+		t.Errorf("klines requested %v after a fresh snapshot, want none", stub.klines)
+	}
+}
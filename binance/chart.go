@@ -0,0 +1,46 @@
+package filter
+
+import (
+	"alertbot/utils/chart"
+)
+
+// chartPoints collect the market data of symbol from first to the latest sample
+// and the indexes of min and max
+func (bf *BinanceFilter) chartPoints(symbol string, first marketdata, min marketdata, max marketdata) ([]chart.Point, int, int) {
+	points := []chart.Point{}
+	minIndex, maxIndex := -1, -1
+
+	for _, data := range bf.market[symbol].samples(first.Time) {
+		if data.Time == min.Time {
+			minIndex = len(points)
+		}
+		if data.Time == max.Time {
+			maxIndex = len(points)
+		}
+
+		points = append(points, chart.Point{Time: data.Time, Price: data.Price, Volume: data.QuoteVolume})
+	}
+
+	return points, minIndex, maxIndex
+}
+
+// renderChart draws the market data of symbol since from, marking its min and max
+func (bf *BinanceFilter) renderChart(symbol string, from int64) ([]byte, error) {
+	samples := bf.market[symbol].samples(from)
+	if len(samples) == 0 {
+		return chart.Render(nil, -1, -1)
+	}
+
+	min, max := samples[0], samples[0]
+	for _, data := range samples {
+		if data.Price <= min.Price {
+			min = data
+		}
+		if data.Price >= max.Price {
+			max = data
+		}
+	}
+
+	points, minIndex, maxIndex := bf.chartPoints(symbol, samples[0], min, max)
+	return chart.Render(points, minIndex, maxIndex)
+}
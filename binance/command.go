@@ -1,142 +1,650 @@
 package filter
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	"alertbot/chat"
+	"alertbot/command"
+	"alertbot/record"
 )
 
-// Ignore filter Binance's message
-func (bf *BinanceFilter) Ignore(s string) {
-	bf.ignored[strings.ToUpper(s+"USDT")] = struct{}{}
-	bf.postMessage(SYSTEM, fmt.Sprintf("%s ignored", strings.ToUpper(s+"USDT")))
+var word = regexp.MustCompile(`[A-Za-z0-9]+`)
+
+// replySymbol finds the symbol of the alert replied to
+func (bf *BinanceFilter) replySymbol(r chat.Request) string {
+	for _, w := range word.FindAllString(r.Reply, -1) {
+		name := strings.ToUpper(w)
+		if _, found := bf.channel[name]; found {
+			continue
+		}
+		if symbol, found := bf.symbolOf(name); found {
+			return symbol
+		}
+	}
+
+	return ""
 }
 
-// Unignore filter Binance's message
-func (bf *BinanceFilter) Unignore(s string) {
-	if _, found := bf.ignored[strings.ToUpper(s+"USDT")]; found {
-		delete(bf.ignored, strings.ToUpper(s+"USDT"))
-		bf.postMessage(SYSTEM, fmt.Sprintf("%s unignored", strings.ToUpper(s+"USDT")))
-	} else {
-		bf.postMessage(SYSTEM, fmt.Sprintf("%s not found", strings.ToUpper(s+"USDT")))
+// Ignore filter Binance's message, for a while with a duration, e.g. "EOS 2h"
+func (bf *BinanceFilter) Ignore(c *command.Context) {
+	symbol, _ := bf.symbolOf(c.String("symbol"))
+	old := bf.ignoreState(symbol)
+	bf.ignored.add(symbol)
+	bf.recordChange(c.Request.User, "ignore", symbol, old, ignoredState)
+	c.Reply(fmt.Sprintf("%s ignored%s", symbol, expire(bf.ignores, symbol, c.Duration("duration"))))
+}
+
+// expire key after duration, never with zero
+func expire(t *timers, key string, duration time.Duration) string {
+	if duration <= 0 {
+		t.set(key, 0)
+		return ""
 	}
+
+	t.set(key, time.Now().Add(duration).UnixMilli())
+	return fmt.Sprintf(" for %s", duration)
 }
 
-// Mute filter Binance's message
-func (bf *BinanceFilter) Mute(s string) {
-	errCheck := func(err bool) bool {
-		if err {
-			bf.postMessage(SYSTEM, "wrong format")
-		}
-		return err
+// Ignored list the ignored symbols, the snoozed ones and the muted channels with their remaining time
+func (bf *BinanceFilter) Ignored(c *command.Context) {
+	now := time.Now().UnixMilli()
+	lines := []string{}
+	for _, symbol := range bf.ignored.list() {
+		lines = append(lines, symbol+" ignored"+remaining(bf.ignores, symbol, now))
 	}
+	sort.Strings(lines)
 
-	if errCheck(s == "") {
-		return
+	if snoozed := bf.snoozes.format("snoozed"); snoozed != "" {
+		lines = append(lines, snoozed)
 	}
 
-	channel := strings.ToUpper(s)
-	if _, found := bf.channel[channel]; errCheck(!found) {
+	for _, channel := range strings.Fields(bf.muted()) {
+		lines = append(lines, channel+" muted"+remaining(bf.mutes, channel, now))
+	}
+
+	if len(lines) == 0 {
+		c.Reply("nothing ignored")
 		return
 	}
 
-	bf.channel[channel].Store(false)
-	bf.postMessage(SYSTEM, "muted")
+	c.Reply(strings.Join(lines, "\n"))
 }
 
-// Unmute filter Binance's message
-func (bf *BinanceFilter) Unmute(content string) {
-	s := strings.Fields(content)
-	errCheck := func(err bool) bool {
-		if err {
-			bf.postMessage(SYSTEM, "wrong format")
+func remaining(t *timers, key string, now int64) string {
+	if d, found := t.remaining(key, now); found {
+		return fmt.Sprintf(" for %s", d)
+	}
+	return ""
+}
+
+// Unignore filter Binance's message
+func (bf *BinanceFilter) Unignore(c *command.Context) {
+	symbol, _ := bf.symbolOf(c.String("symbol"))
+	if bf.ignored.remove(symbol) {
+		bf.ignores.set(symbol, 0)
+		bf.recordChange(c.Request.User, "unignore", symbol, ignoredState, "")
+		c.Reply(fmt.Sprintf("%s unignored", symbol))
+	} else {
+		c.Reply(fmt.Sprintf("%s not found", symbol))
+	}
+}
+
+// Snooze silence a symbol for a while, e.g. "EOS 1h", "EOS 0" to stop
+func (bf *BinanceFilter) Snooze(c *command.Context) {
+	if !c.Has("symbol") {
+		if snoozed := bf.snoozes.format("snoozed"); snoozed != "" {
+			c.Reply(snoozed)
+		} else {
+			c.Reply("no snooze")
 		}
-		return err
+		return
+	}
+
+	symbol, _ := bf.symbolOf(c.String("symbol"))
+	duration := time.Hour
+	if c.Has("duration") {
+		duration = c.Duration("duration")
 	}
 
-	if errCheck(len(s) != 1) {
+	if duration == 0 {
+		bf.snoozes.set(symbol, 0)
+		c.Reply(fmt.Sprintf("%s unsnoozed", symbol))
 		return
 	}
 
-	channel := strings.ToUpper(s[0])
+	bf.snoozes.set(symbol, time.Now().Add(duration).UnixMilli())
+	c.Reply(fmt.Sprintf("%s snoozed for %s", symbol, duration))
+}
+
+// Mute filter Binance's message, for a while with a duration, e.g. "BUY 30m"
+func (bf *BinanceFilter) Mute(c *command.Context) {
+	channel, old := strings.ToUpper(c.String("channel")), bf.muted()
+	bf.channel[channel].Store(false)
+	bf.recordChange(c.Request.User, "mute", channel, old, bf.muted())
+	c.Reply("muted" + expire(bf.mutes, channel, c.Duration("duration")))
+}
+
+// Unmute filter Binance's message
+func (bf *BinanceFilter) Unmute(c *command.Context) {
+	channel, old := strings.ToUpper(c.String("channel")), bf.muted()
 	if channel == ALL {
 		for c := range bf.channel {
 			if !bf.channel[c].Load() {
 				bf.channel[c].Store(true)
 			}
+			bf.mutes.set(c, 0)
 		}
 	} else {
 		bf.channel[channel].Store(true)
+		bf.mutes.set(channel, 0)
 		if !bf.channel[ALL].Load() {
 			bf.channel[ALL].Store(true)
 		}
 	}
-	bf.postMessage(SYSTEM, "unmuted")
+	bf.recordChange(c.Request.User, "unmute", channel, old, bf.muted())
+	c.Reply("unmuted")
 }
 
-// Filter symbol
-func (bf *BinanceFilter) Filter(s string) {
-	bf.futureFilter.Store(strings.ToUpper(s))
+// Cooldown set the minimum interval between alerts of a channel or a symbol
+func (bf *BinanceFilter) Cooldown(c *command.Context) {
+	if !c.Has("target") {
+		c.Reply(bf.throttle.String())
+		return
+	}
+
+	if !c.Has("duration") {
+		c.Invalid("duration", "missing")
+		return
+	}
+
+	cooldown := c.Duration("duration")
+	if cooldown < 0 {
+		c.Invalid("duration", "negative")
+		return
+	}
+
+	name := strings.ToUpper(c.String("target"))
+	if _, found := bf.channel[name]; found && name != ALL {
+		bf.throttle.setChannelCooldown(name, cooldown.Milliseconds())
+	} else if symbol, found := bf.symbolOf(name); found {
+		name = symbol
+		bf.throttle.setSymbolCooldown(name, cooldown.Milliseconds())
+	} else {
+		c.Reply("not found")
+		return
+	}
+	c.Reply(fmt.Sprintf("%s cooldown to %s", name, cooldown))
 }
 
-// Clear filter symbol
-func (bf *BinanceFilter) Clear(s string) {
-	bf.futureFilter.Store("")
+// Budget set the maximum alerts per hour of a channel
+func (bf *BinanceFilter) Budget(c *command.Context) {
+	if !c.Has("channel") {
+		c.Reply(bf.throttle.String())
+		return
+	}
+
+	channel := strings.ToUpper(c.String("channel"))
+	if channel == ALL {
+		c.Invalid("channel", "ALL has no budget")
+		return
+	}
+
+	if !c.Has("count") {
+		c.Invalid("count", "missing")
+		return
+	}
+
+	budget := c.Int("count")
+	if budget < 0 {
+		c.Invalid("count", "negative")
+		return
+	}
+
+	bf.throttle.setBudget(channel, int(budget))
+	c.Reply(fmt.Sprintf("%s budget to %d per hour", channel, budget))
 }
 
-// Price get
-func (bf *BinanceFilter) Price(s string) {
-	symbol := strings.ToUpper(s + "USDT")
-	if _, found := bf.market[symbol]; found {
-		bf.postMessage(SYSTEM, strconv.FormatFloat(bf.market[symbol].Back().Value.(*marketdata).Price, 'f', -1, 64))
+// Watchlists list
+func (bf *BinanceFilter) Watchlists(c *command.Context) {
+	c.Reply(bf.watchlists.format(bf.short))
+}
+
+// WatchAdd add symbols to a watchlist
+func (bf *BinanceFilter) WatchAdd(c *command.Context) {
+	name := strings.ToLower(c.String("name"))
+	if err := bf.watchlists.add(name, bf.symbolsOf(c.Strings("symbol"))); err != nil {
+		c.Reply(err.Error())
+		return
+	}
+	c.Reply(fmt.Sprintf("%s watched", name))
+}
+
+// WatchRemove remove symbols from a watchlist, the whole watchlist without symbol
+func (bf *BinanceFilter) WatchRemove(c *command.Context) {
+	name := strings.ToLower(c.String("name"))
+	if found, err := bf.watchlists.remove(name, bf.symbolsOf(c.Strings("symbol"))); err != nil {
+		c.Reply(err.Error())
+		return
+	} else if !found {
+		c.Reply(fmt.Sprintf("%s not found", name))
+		return
+	}
+	c.Reply(fmt.Sprintf("%s updated", name))
+}
+
+// WatchSet set a threshold of a watchlist
+func (bf *BinanceFilter) WatchSet(c *command.Context) {
+	name, key, threshold := strings.ToLower(c.String("name")), c.String("key"), c.Float("value")
+	if (key == "down") != (threshold < 0) || threshold == 0 {
+		c.Invalid("value", "must be negative for down and positive otherwise")
+		return
+	}
+
+	if found, err := bf.watchlists.setThreshold(name, key, threshold); err != nil {
+		c.Reply(err.Error())
+		return
+	} else if !found {
+		c.Reply(fmt.Sprintf("%s not found", name))
+		return
+	}
+	c.Reply(fmt.Sprintf("%s %s to %g", name, key, threshold))
+}
+
+// WatchRoute send the alerts of a watchlist to another chat, the default one without destination
+func (bf *BinanceFilter) WatchRoute(c *command.Context) {
+	name, route := strings.ToLower(c.String("name")), c.String("destination")
+	if found, err := bf.watchlists.setRoute(name, route); err != nil {
+		c.Reply(err.Error())
+		return
+	} else if !found {
+		c.Reply(fmt.Sprintf("%s not found", name))
+		return
+	}
+	c.Reply(fmt.Sprintf("%s routed to %s", name, route))
+}
+
+func (bf *BinanceFilter) symbolsOf(names []string) []string {
+	ret := []string{}
+	for _, name := range names {
+		symbol, _ := bf.symbolOf(name)
+		ret = append(ret, symbol)
+	}
+
+	return ret
+}
+
+// Filter restrict a channel to a watchlist, e.g. "FSELL defi"
+func (bf *BinanceFilter) Filter(c *command.Context) {
+	if !c.Has("channel") {
+		c.Reply(bf.watchlists.format(bf.short))
+		return
+	}
+
+	if !c.Has("watchlist") {
+		c.Invalid("watchlist", "missing")
+		return
+	}
+
+	channel, name := strings.ToUpper(c.String("channel")), strings.ToLower(c.String("watchlist"))
+	old := bf.watchlists.restrictions()
+	if found, err := bf.watchlists.restrict(channel, name); err != nil {
+		c.Reply(err.Error())
+		return
+	} else if !found {
+		c.Reply(fmt.Sprintf("%s not found", name))
+		return
+	}
+	bf.recordChange(c.Request.User, "filter", channel, old, bf.watchlists.restrictions())
+	c.Reply(fmt.Sprintf("%s restricted to %s", channel, name))
+}
+
+// Clear channel restriction, all channels without argument
+func (bf *BinanceFilter) Clear(c *command.Context) {
+	channel, old := ALL, bf.watchlists.restrictions()
+	var err error
+	if !c.Has("channel") {
+		err = bf.watchlists.clear()
 	} else {
-		bf.postMessage(SYSTEM, "not found")
+		channel = strings.ToUpper(c.String("channel"))
+		_, err = bf.watchlists.restrict(channel, "")
+	}
+	if err != nil {
+		c.Reply(err.Error())
+		return
 	}
+	bf.recordChange(c.Request.User, "clear", channel, old, bf.watchlists.restrictions())
+	c.Reply("cleared")
+}
+
+// Price get
+func (bf *BinanceFilter) Price(c *command.Context) {
+	symbol, _ := bf.symbolOf(c.String("symbol"))
+	c.Reply(strconv.FormatFloat(bf.market[symbol].last().Price, 'f', -1, 64))
 }
 
 // FundingRate get
-func (bf *BinanceFilter) FundingRate(s string) {
-	symbol := strings.ToUpper(s)
-	if !strings.Contains(s, "USDT") {
-		symbol += "USDT"
-	}
+func (bf *BinanceFilter) FundingRate(c *command.Context) {
+	symbol := bf.fundingSymbol(c.String("symbol"))
 	if _, found := bf.funding[symbol]; found {
-		bf.postMessage(SYSTEM, fmt.Sprintf("%0.4f", bf.funding[symbol].Load()))
+		c.Reply(fmt.Sprintf("%0.4f", bf.funding[symbol].Load()))
 	} else {
-		bf.postMessage(SYSTEM, "not found")
+		c.Reply("not found")
 	}
 }
 
-// FundingRateTop get
-func (bf *BinanceFilter) FundingRateTop(s string) {
-	top, err := strconv.ParseUint(s, 10, 64)
-	if err != nil || int(top) >= len(bf.funding) {
-		top = 3
+// fundingSymbol of a name, either a futures symbol or a base paired with the first quote asset listing it, else its COIN-M perpetual
+func (bf *BinanceFilter) fundingSymbol(name string) string {
+	name = strings.ToUpper(name)
+	if _, found := bf.funding[name]; found {
+		return name
 	}
-	symbols := make([]string, 0, len(bf.funding))
-	for symbol := range bf.funding {
-		symbols = append(symbols, symbol)
+
+	for _, quote := range bf.quotes {
+		if _, found := bf.funding[name+quote]; found {
+			return name + quote
+		}
 	}
 
-	sort.SliceStable(symbols, func(i, j int) bool {
-		return bf.funding[symbols[i]].Load() > bf.funding[symbols[j]].Load()
-	})
+	// COIN-M perpetual
+	if _, found := bf.funding[name+"USD_PERP"]; found {
+		return name + "USD_PERP"
+	}
+
+	return name
+}
+
+// FundingRateTop get
+func (bf *BinanceFilter) FundingRateTop(c *command.Context) {
+	symbols := bf.fundingRanking()
+	top := bf.fundingCount(c, len(symbols))
 
 	ret := ""
 	for _, symbol := range symbols[:top] {
 		ret = ret + fmt.Sprintf("%s: %s\n", symbol, fmt.Sprintf("%0.4f", bf.funding[symbol].Load()))
 	}
 
-	bf.postMessage(SYSTEM, ret)
+	c.Reply(ret)
 }
 
 // FundingRateBottom get
-func (bf *BinanceFilter) FundingRateBottom(s string) {
-	bot, err := strconv.ParseUint(s, 10, 64)
-	if err != nil || int(bot) >= len(bf.funding) {
-		bot = 3
+func (bf *BinanceFilter) FundingRateBottom(c *command.Context) {
+	symbols := bf.fundingRanking()
+	bot := bf.fundingCount(c, len(symbols))
+
+	ret := ""
+	for i := 1; i <= bot; i++ {
+		ret = ret + fmt.Sprintf("%s: %s\n", symbols[len(symbols)-i], fmt.Sprintf("%0.4f", bf.funding[symbols[len(symbols)-i]].Load()))
 	}
+
+	c.Reply(ret)
+}
+
+// fundingCount of symbols to list, 3 by default
+func (bf *BinanceFilter) fundingCount(c *command.Context, total int) int {
+	count := c.Int("count")
+	if count <= 0 || int(count) >= total {
+		count = 3
+	}
+	if int(count) > total {
+		count = int64(total)
+	}
+
+	return int(count)
+}
+
+// Chart of a symbol, e.g. "SOL 30m"
+func (bf *BinanceFilter) Chart(c *command.Context) {
+	symbol, _ := bf.symbolOf(c.String("symbol"))
+	window := time.Duration(bf.windowThreshold.Load()) * time.Millisecond
+	if c.Has("window") {
+		window = c.Duration("window")
+		if window <= 0 || window.Milliseconds() > bf.retention.Load() {
+			c.Invalid("window", "must be positive and within the retention")
+			return
+		}
+	}
+
+	img, err := bf.renderChart(symbol, time.Now().Add(-window).UnixMilli())
+	if err != nil {
+		c.Reply(err.Error())
+		return
+	}
+
+	c.ReplyPhoto(fmt.Sprintf("#%s %s", bf.short(symbol), window), img)
+}
+
+// Reports list
+func (bf *BinanceFilter) Reports(c *command.Context) {
+	c.Reply(bf.reports.String())
+}
+
+// ReportNow post a report of the last window
+func (bf *BinanceFilter) ReportNow(c *command.Context) {
+	window := time.Hour
+	if c.Has("window") {
+		window = c.Duration("window")
+		if window <= 0 {
+			c.Invalid("window", "must be positive")
+			return
+		}
+	}
+	c.Reply(bf.report(window))
+}
+
+// ReportRemove remove a scheduled report
+func (bf *BinanceFilter) ReportRemove(c *command.Context) {
+	id, err := parseID(c.String("id"))
+	if err != nil {
+		c.Invalid("id", err.Error())
+		return
+	}
+
+	found, err := bf.reports.remove(id)
+	if err != nil {
+		c.Reply(err.Error())
+	} else if !found {
+		c.Reply("not found")
+	} else {
+		c.Reply("removed")
+	}
+}
+
+// ReportSchedule schedule a report, e.g. "every 4h", "at 08:00 12h"
+func (bf *BinanceFilter) ReportSchedule(kind string) func(c *command.Context) {
+	return func(c *command.Context) {
+		s := []string{kind, c.String("when")}
+		if c.Has("window") {
+			s = append(s, c.String("window"))
+		}
+
+		schedule, err := parseSchedule(s)
+		if err != nil {
+			c.Invalid("when", "interval from 1m to 24h in minutes, time as 15:04 and window up to 24h")
+			return
+		}
+		schedule, err = bf.reports.add(schedule, time.Now())
+		if err != nil {
+			c.Reply(err.Error())
+			return
+		}
+		c.Reply(fmt.Sprintf("report %s", schedule))
+	}
+}
+
+// query of the target and since arguments, false if replied invalid
+func (bf *BinanceFilter) query(c *command.Context) (record.Query, bool) {
+	q := record.Query{}
+	if !bf.parseTarget(c.String("target"), &q) {
+		c.Invalid("target", "not a channel or a symbol")
+		return q, false
+	}
+
+	from, err := bf.parseSince(since(c))
+	if err != nil {
+		c.Invalid("since", "today, a number of days or a duration")
+		return q, false
+	}
+	q.From = from
+
+	if bf.store == nil {
+		c.Reply("no history")
+		return q, false
+	}
+
+	return q, true
+}
+
+// since argument, 24h by default
+func since(c *command.Context) string {
+	if c.Has("since") {
+		return c.String("since")
+	}
+	return "24h"
+}
+
+// History list stored alerts, e.g. "SOL 24h", "FSELL 1h top 10"
+func (bf *BinanceFilter) History(c *command.Context) {
+	q, ok := bf.query(c)
+	if !ok {
+		return
+	}
+
+	top := 0
+	if c.Has("top") {
+		if !c.Has("count") || c.Int("count") <= 0 || c.Int("count") > 255 {
+			c.Invalid("count", "from 1 to 255")
+			return
+		}
+		top = int(c.Int("count"))
+	}
+
+	alerts, err := bf.store.Find(q)
+	if err != nil {
+		c.Reply(err.Error())
+		return
+	}
+
+	if top > 0 {
+		alerts = rankAlerts(alerts, top)
+	} else if len(alerts) > 20 {
+		alerts = alerts[len(alerts)-20:]
+	}
+
+	if len(alerts) == 0 {
+		c.Reply("no alert")
+		return
+	}
+
+	lines := make([]string, 0, len(alerts))
+	for i := range alerts {
+		lines = append(lines, bf.formatRecord(&alerts[i]))
+	}
+	c.Reply(strings.Join(lines, "\n"))
+}
+
+// Count stored alerts, e.g. "UP today", "SOL 24h"
+func (bf *BinanceFilter) Count(c *command.Context) {
+	q, ok := bf.query(c)
+	if !ok {
+		return
+	}
+
+	count := 0
+	if err := bf.store.Each(q, func(a *record.Alert) error {
+		count++
+		return nil
+	}); err != nil {
+		c.Reply(err.Error())
+		return
+	}
+
+	c.Reply(fmt.Sprintf("%s %s: %d", strings.ToUpper(c.String("target")), since(c), count))
+}
+
+// Performance of stored alerts after their forward returns, e.g. "UP 7d"
+func (bf *BinanceFilter) Performance(c *command.Context) {
+	q, ok := bf.query(c)
+	if !ok {
+		return
+	}
+
+	ret, err := bf.performance(q)
+	if err != nil {
+		c.Reply(err.Error())
+		return
+	}
+	c.Reply(ret)
+}
+
+// Webhooks list the webhook routes
+func (bf *BinanceFilter) Webhooks(c *command.Context) {
+	if bf.webhook == nil {
+		c.Reply("no webhook")
+		return
+	}
+
+	lines := []string{}
+	for _, r := range bf.webhook.Routes() {
+		lines = append(lines, r.String())
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "no route")
+	}
+	c.Reply(strings.Join(lines, "\n"))
+}
+
+// WebhookAdd route a channel to a URL, e.g. "FSELL https://example.com/hook secret"
+func (bf *BinanceFilter) WebhookAdd(c *command.Context) {
+	if bf.webhook == nil {
+		c.Reply("no webhook")
+		return
+	}
+
+	if u, err := url.Parse(c.String("url")); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		c.Invalid("url", "not an http(s) URL")
+		return
+	}
+
+	r, err := bf.webhook.AddRoute(strings.ToUpper(c.String("channel")), c.String("url"), c.String("secret"))
+	if err != nil {
+		c.Reply(err.Error())
+		return
+	}
+	c.Reply(fmt.Sprintf("webhook %s", r))
+}
+
+// WebhookRemove remove a webhook route
+func (bf *BinanceFilter) WebhookRemove(c *command.Context) {
+	if bf.webhook == nil {
+		c.Reply("no webhook")
+		return
+	}
+
+	id, err := parseID(c.String("id"))
+	if err != nil || id <= 0 {
+		c.Invalid("id", "not a route")
+		return
+	}
+
+	found, err := bf.webhook.RemoveRoute(uint64(id))
+	if err != nil {
+		c.Reply(err.Error())
+	} else if !found {
+		c.Reply("not found")
+	} else {
+		c.Reply("removed")
+	}
+}
+
+// fundingRanking symbols by funding rate, highest first
+func (bf *BinanceFilter) fundingRanking() []string {
 	symbols := make([]string, 0, len(bf.funding))
 	for symbol := range bf.funding {
 		symbols = append(symbols, symbol)
@@ -146,16 +654,11 @@ func (bf *BinanceFilter) FundingRateBottom(s string) {
 		return bf.funding[symbols[i]].Load() > bf.funding[symbols[j]].Load()
 	})
 
-	ret := ""
-	for i := 1; i <= int(bot); i++ {
-		ret = ret + fmt.Sprintf("%s: %s\n", symbols[len(symbols)-i], fmt.Sprintf("%0.4f", bf.funding[symbols[len(symbols)-i]].Load()))
-	}
-
-	bf.postMessage(SYSTEM, ret)
+	return symbols
 }
 
 // Restart to filter Binance's events
-func (bf *BinanceFilter) Restart(settings string) {
+func (bf *BinanceFilter) Restart(c *command.Context) {
 	bf.stopCMarketsStatServe <- struct{}{}
 	bf.stopCCombinedTrade <- struct{}{}
 	bf.stopCFutureCombinedTrade <- struct{}{}
@@ -164,125 +667,153 @@ func (bf *BinanceFilter) Restart(settings string) {
 	go bf.handleWsAllMarketsStat()
 	go bf.handleWsFutureCombinedTrade()
 	go bf.handleWsCombinedTrade()
+	bf.startDelivery()
+	go bf.backfill()
+
+	bf.recordChange(c.Request.User, "restart", "", "", "")
 }
 
-// UpdateConfiguration from message bot command
-func (bf *BinanceFilter) UpdateConfiguration(settings string) {
-	s := strings.Fields(settings)
-	errCheck := func(err bool) bool {
-		if err {
-			bf.postMessage(SYSTEM, "wrong format")
-		}
-		return err
-	}
+// configurable thresholds of /set
+var configurable = []string{"srate", "frate", "minvolume", "maxvolume", "slarge", "flarge", "window", "up", "down", "volume", "priority", "retention"}
 
-	if errCheck(len(s) != 2) {
+// UpdateConfiguration from message bot command
+func (bf *BinanceFilter) UpdateConfiguration(c *command.Context) {
+	key, threshold := c.String("key"), c.Float("value")
+	old := bf.configuration(key)
+	reply, err := bf.setConfiguration(key, threshold)
+	if err != nil {
+		c.Invalid("value", err.Error())
 		return
 	}
 
-	configurable := map[string]struct{}{
-		"srate":     {},
-		"frate":     {},
-		"minvolume": {},
-		"maxvolume": {},
-		"slarge":    {},
-		"flarge":    {},
-		"window":    {},
-		"up":        {},
-		"down":      {},
-		"volume":    {},
-	}
+	bf.recordChange(c.Request.User, "set", key, strconv.FormatFloat(old, 'f', -1, 64), strconv.FormatFloat(threshold, 'f', -1, 64))
+	c.Reply(reply)
+}
 
-	if _, found := configurable[s[0]]; errCheck(!found) {
-		return
+// validateConfiguration of a threshold of /set
+func validateConfiguration(key string, threshold float64) error {
+	switch key {
+	case "srate", "frate", "minvolume", "maxvolume", "slarge", "flarge", "up", "volume":
+		if threshold <= 0 {
+			return errors.New("must be positive")
+		}
+	case "window":
+		if threshold <= 0 || threshold > 60 {
+			return errors.New("minutes from 0 to 60")
+		}
+	case "down":
+		if threshold >= 0 {
+			return errors.New("must be negative")
+		}
+	case "priority":
+		if threshold < 1 {
+			return errors.New("must be at least 1")
+		}
+	case "retention":
+		if threshold < 1 || int64(threshold*float64(milliInHour)) > maxRetention {
+			return errors.New("hours from 1 to 48")
+		}
+	default:
+		return errNotFound
 	}
 
-	threshold, err := strconv.ParseFloat(s[1], 64)
-	if errCheck(err != nil) {
-		return
+	return nil
+}
+
+// setConfiguration validate and store a threshold of /set, returning the reply
+func (bf *BinanceFilter) setConfiguration(key string, threshold float64) (string, error) {
+	if err := validateConfiguration(key, threshold); err != nil {
+		return "", err
 	}
 
-	switch {
-	case s[0] == "srate":
-		if errCheck(threshold <= 0) {
-			return
-		}
+	switch key {
+	case "srate":
 		bf.sRateThreshold.Store(threshold)
-		bf.postMessage(SYSTEM, fmt.Sprintf("SRate to %0.2f%%\n", threshold))
-	case s[0] == "frate":
-		if errCheck(threshold <= 0) {
-			return
-		}
+		return fmt.Sprintf("SRate to %0.2f%%\n", threshold), nil
+	case "frate":
 		bf.fRateThreshold.Store(threshold)
-		bf.postMessage(SYSTEM, fmt.Sprintf("FRate to %0.2f%%\n", threshold))
-	case s[0] == "minvolume":
-		if errCheck(threshold <= 0) {
-			return
-		}
+		return fmt.Sprintf("FRate to %0.2f%%\n", threshold), nil
+	case "minvolume":
 		bf.minQuoteThreshold.Store(threshold)
-		bf.postMessage(SYSTEM, bf.printer.Sprintf("Min Volume to %d$\n", int64(threshold)))
-	case s[0] == "maxvolume":
-		if errCheck(threshold <= 0) {
-			return
-		}
+		return bf.printer.Sprintf("Min Volume to %d$\n", int64(threshold)), nil
+	case "maxvolume":
 		bf.maxQuoteThreshold.Store(threshold)
-		bf.postMessage(SYSTEM, bf.printer.Sprintf("Max Volume to %d$\n", int64(threshold)))
-	case s[0] == "slarge":
-		if errCheck(threshold <= 0) {
-			return
-		}
+		return bf.printer.Sprintf("Max Volume to %d$\n", int64(threshold)), nil
+	case "slarge":
 		bf.largeSThreshold.Store(threshold)
-		bf.postMessage(SYSTEM, bf.printer.Sprintf("SLarge to %d$\n", int64(threshold)))
-	case s[0] == "flarge":
-		if errCheck(threshold <= 0) {
-			return
-		}
+		return bf.printer.Sprintf("SLarge to %d$\n", int64(threshold)), nil
+	case "flarge":
 		bf.largeFThreshold.Store(threshold)
-		bf.postMessage(SYSTEM, bf.printer.Sprintf("FLarge to %d$\n", int64(threshold)))
-	case s[0] == "window":
-		if errCheck(threshold <= 0 || threshold > 60) {
-			return
-		}
+		return bf.printer.Sprintf("FLarge to %d$\n", int64(threshold)), nil
+	case "window":
 		bf.windowThreshold.Store(int64(threshold * float64(milliInMin)))
-		bf.postMessage(SYSTEM, fmt.Sprintf("Window to %0.2f minutes(s)", threshold))
-	case s[0] == "up":
-		if errCheck(threshold <= 0) {
-			return
-		}
+		return fmt.Sprintf("Window to %0.2f minutes(s)", threshold), nil
+	case "up":
 		bf.upThreshold.Store(threshold)
-		bf.postMessage(SYSTEM, fmt.Sprintf("Up to %0.2f%%\n", threshold))
-	case s[0] == "down":
-		if errCheck(threshold >= 0) {
-			return
-		}
+		return fmt.Sprintf("Up to %0.2f%%\n", threshold), nil
+	case "down":
 		bf.downThreshold.Store(threshold)
-		bf.postMessage(SYSTEM, fmt.Sprintf("Down to %0.2f%%\n", threshold))
-	case s[0] == "volume":
-		if errCheck(threshold <= 0) {
-			return
-		}
+		return fmt.Sprintf("Down to %0.2f%%\n", threshold), nil
+	case "volume":
 		bf.volumeThreshold.Store(threshold)
-		bf.postMessage(SYSTEM, fmt.Sprintf("Volume to %0.2f%%\n", threshold))
+		return fmt.Sprintf("Volume to %0.2f%%\n", threshold), nil
+	case "priority":
+		bf.priorityThreshold.Store(threshold)
+		return fmt.Sprintf("Priority to %0.2fx\n", threshold), nil
+	case "retention":
+		bf.retention.Store(int64(threshold * float64(milliInHour)))
+		return fmt.Sprintf("Retention to %0.2f hour(s)", threshold), nil
 	}
+
+	return "", errNotFound
+}
+
+// configuration value of a threshold of /set, in the unit of /set
+func (bf *BinanceFilter) configuration(key string) float64 {
+	switch key {
+	case "srate":
+		return bf.sRateThreshold.Load()
+	case "frate":
+		return bf.fRateThreshold.Load()
+	case "minvolume":
+		return bf.minQuoteThreshold.Load()
+	case "maxvolume":
+		return bf.maxQuoteThreshold.Load()
+	case "slarge":
+		return bf.largeSThreshold.Load()
+	case "flarge":
+		return bf.largeFThreshold.Load()
+	case "window":
+		return float64(bf.windowThreshold.Load()) / float64(milliInMin)
+	case "up":
+		return bf.upThreshold.Load()
+	case "down":
+		return bf.downThreshold.Load()
+	case "volume":
+		return bf.volumeThreshold.Load()
+	case "priority":
+		return bf.priorityThreshold.Load()
+	case "retention":
+		return float64(bf.retention.Load()) / float64(milliInHour)
+	}
+
+	return 0
 }
 
 // UpdateData from message bot command
-func (bf *BinanceFilter) UpdateData(settings string) {
+func (bf *BinanceFilter) UpdateData(c *command.Context) {
 	if bf.updateData() == nil {
-		bf.postMessage(SYSTEM, "updated")
+		go bf.backfill()
+		c.Reply("updated")
 	} else {
-		bf.postMessage(SYSTEM, "failed")
+		c.Reply("failed")
 	}
 }
 
 // GetConfiguration list current configuration
-func (bf *BinanceFilter) GetConfiguration(setting string) {
-	if setting != "config" {
-		bf.postMessage(SYSTEM, "unsupported")
-		return
-	}
-
-	bf.postMessage(SYSTEM, bf.printer.Sprintf("SRate: %0.2f%%\nFRate: %0.2f%%\nMin Volume: %d$\nMax Volume: %d$\nSLarge: %d$\nFLarge: %d$\nWindow: %0.2f minute(s)\nUp: %0.2f%%\nDown: %0.2f%%\nVolume: %0.2f%%",
+func (bf *BinanceFilter) GetConfiguration(c *command.Context) {
+	c.Reply(bf.printer.Sprintf("SRate: %0.2f%%\nFRate: %0.2f%%\nMin Volume: %d$\nMax Volume: %d$\nSLarge: %d$\nFLarge: %d$\nWindow: %0.2f minute(s)\nUp: %0.2f%%\nDown: %0.2f%%\nVolume: %0.2f%%\nPriority: %0.2fx\nRetention: %0.2f hour(s)",
 		bf.sRateThreshold.Load(), bf.fRateThreshold.Load(), int64(bf.minQuoteThreshold.Load()), int64(bf.maxQuoteThreshold.Load()), int64(bf.largeSThreshold.Load()), int64(bf.largeFThreshold.Load()),
-		float64(bf.windowThreshold.Load())/float64(milliInMin), bf.upThreshold.Load(), bf.downThreshold.Load(), bf.volumeThreshold.Load()))
+		float64(bf.windowThreshold.Load())/float64(milliInMin), bf.upThreshold.Load(), bf.downThreshold.Load(), bf.volumeThreshold.Load(), bf.priorityThreshold.Load(),
+		float64(bf.retention.Load())/float64(milliInHour)))
 }
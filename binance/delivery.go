@@ -0,0 +1,179 @@
+package filter
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/delivery"
+
+	"alertbot/record"
+)
+
+const (
+	coinMarket     = "C"             // tags the alerts of the COIN-M futures
+	reconnectDelay = 5 * time.Second // before serving again a COIN-M stream that failed
+)
+
+// contract of a COIN-M perpetual, e.g. BTCUSD_PERP
+type contract struct {
+	spot string  // symbol of the spot market of its base, empty if not tracked
+	pair string  // e.g. BTCUSD
+	size float64 // USD value of a contract
+}
+
+// spotOf a symbol, the spot market of a COIN-M contract and the symbol itself otherwise
+func (bf *BinanceFilter) spotOf(symbol string) string {
+	if c, found := bf.contracts[symbol]; found && c.spot != "" {
+		return c.spot
+	}
+	return symbol
+}
+
+// startDelivery (re)start the COIN-M streams, stopping the running ones
+func (bf *BinanceFilter) startDelivery() {
+	bf.deliveryMu.Lock()
+	defer bf.deliveryMu.Unlock()
+
+	if bf.stopCDelivery != nil {
+		close(bf.stopCDelivery)
+	}
+	stop := make(chan struct{})
+	bf.stopCDelivery = stop
+
+	go bf.handleWsDeliveryAggTrade(stop)
+	go bf.handleWsDeliveryMarkPrice(stop)
+}
+
+// handleWsDeliveryAggTrade watch the trades of the COIN-M perpetuals until stop is closed, one stream each as delivery has no combined one
+func (bf *BinanceFilter) handleWsDeliveryAggTrade(stop chan struct{}) {
+	wsAggTradeHandler := func(event *delivery.WsAggTradeEvent) {
+		c, found := bf.contracts[event.Symbol]
+		if !found || c.spot == "" {
+			return
+		}
+
+		if bf.ignored.has(c.spot) {
+			return
+		}
+
+		quantity, err := strconv.ParseFloat(event.Quantity, 64)
+		if err != nil {
+			return
+		}
+
+		price, err := strconv.ParseFloat(event.Price, 64)
+		if err != nil || price == 0 {
+			return
+		}
+
+		maketData := bf.market[c.spot].last()
+
+		if maketData.BaseVolume == 0 ||
+			bf.usd(c.spot, maketData.QuoteVolume) < bf.threshold(c.spot, "minvolume", bf.minQuoteThreshold) ||
+			bf.usd(c.spot, maketData.QuoteVolume) > bf.threshold(c.spot, "maxvolume", bf.maxQuoteThreshold) {
+			return
+		}
+
+		// the quantity is in contracts of a fixed USD value
+		value := quantity * c.size
+		rate := value / price * 100 / maketData.BaseVolume
+		channel := FBUY
+		rateThreshold := bf.threshold(c.spot, "frate", bf.fRateThreshold)
+		largeThreshold := bf.threshold(c.spot, "flarge", bf.largeFThreshold)
+		if rate >= rateThreshold || value >= largeThreshold {
+			if event.Maker {
+				channel = FSELL
+			}
+
+			if bf.excluded(channel, c.spot) {
+				return
+			}
+
+			msg := bf.templates.Alert(&record.Alert{Channel: channel, Symbol: event.Symbol, Base: bf.baseOf(c.spot), Quote: bf.quoteOf(c.spot), Market: coinMarket,
+				Price: price, SpotPrice: maketData.Price, Rate: rate, Value: value, Quantity: quantity, Threshold: rateThreshold, Time: event.TradeTime})
+			log.Println(msg.Render(bf.logFormat))
+			bf.postAlert(msg, nil)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for symbol, c := range bf.contracts {
+		if c.spot == "" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(symbol string) {
+			defer wg.Done()
+			serveUntil(stop, symbol, func(errHandler delivery.ErrHandler) (chan struct{}, chan struct{}, error) {
+				return delivery.WsAggTradeServe(symbol, wsAggTradeHandler, errHandler)
+			})
+		}(symbol)
+	}
+	wg.Wait()
+}
+
+// handleWsDeliveryMarkPrice watch the funding rates of the COIN-M perpetuals until stop is closed, one stream by pair
+func (bf *BinanceFilter) handleWsDeliveryMarkPrice(stop chan struct{}) {
+	wsMarkPriceHandler := func(event *delivery.WsMarkPriceEvent) {
+		funding, found := bf.funding[event.Symbol]
+		if !found {
+			return
+		}
+
+		fundingRate, err := strconv.ParseFloat(event.FundingRate, 64)
+		if err != nil {
+			return
+		}
+
+		funding.Store(fundingRate * 100)
+	}
+
+	pairs := map[string]struct{}{}
+	for _, c := range bf.contracts {
+		pairs[c.pair] = struct{}{}
+	}
+
+	var wg sync.WaitGroup
+	for pair := range pairs {
+		wg.Add(1)
+		go func(pair string) {
+			defer wg.Done()
+			serveUntil(stop, pair, func(errHandler delivery.ErrHandler) (chan struct{}, chan struct{}, error) {
+				return delivery.WsMarkPriceServe(pair, wsMarkPriceHandler, errHandler)
+			})
+		}(pair)
+	}
+	wg.Wait()
+}
+
+// serveUntil serve a COIN-M stream until stop is closed, again after a failure
+// as one of the many streams must not take the others down
+func serveUntil(stop chan struct{}, name string, serve func(errHandler delivery.ErrHandler) (chan struct{}, chan struct{}, error)) {
+	errHandler := func(err error) {
+		log.Printf("COIN-M %s: %v\n", name, err)
+	}
+
+	for {
+		doneC, stopC, err := serve(errHandler)
+		if err != nil {
+			errHandler(err)
+		} else {
+			select {
+			case <-stop:
+				close(stopC)
+				<-doneC
+				return
+			case <-doneC:
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
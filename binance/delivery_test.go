@@ -0,0 +1,29 @@
+package filter
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestStartDeliveryTwice(t *testing.T) {
+	bf, _ := newTestFilter(t)
+
+	// quick restarts must neither close a stop channel twice nor race on it
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bf.startDelivery()
+		}()
+	}
+	wg.Wait()
+
+	bf.deliveryMu.Lock()
+	defer bf.deliveryMu.Unlock()
+	select {
+	case <-bf.stopCDelivery:
+		t.Error("the running streams are stopped")
+	default:
+	}
+}
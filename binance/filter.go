@@ -2,11 +2,10 @@ package filter
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"os"
 	"strconv"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/adshao/go-binance/v2"
@@ -15,7 +14,11 @@ import (
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 
-	"alertbot/utils/list"
+	"alertbot/command"
+	"alertbot/format"
+	"alertbot/record"
+	"alertbot/utils/chart"
+	"alertbot/webhook"
 )
 
 const (
@@ -23,7 +26,7 @@ const (
 	milliInSec        = 1000
 	milliInMin        = milliInSec * 60
 	milliInHour       = milliInMin * 60
-	milliInDay        = milliInMin * 24
+	milliInDay        = milliInHour * 24
 )
 
 const (
@@ -44,21 +47,49 @@ type marketdata struct {
 	Time        int64
 }
 
+func (d marketdata) time() int64 { return d.Time }
+
+func (d marketdata) price() float64 { return d.Price }
+
 type alertdata struct {
-	Time       int64
+	Time       int64 // of the last alert passing the shared thresholds, they alone count the streaks
 	UpNumber   int
 	DownNumber int
+	SubTime    int64 `json:",omitempty"` // of the last alert passing the thresholds of a subscription only
 }
 
 // BinanceFilter for Binance data filtering
 type BinanceFilter struct {
 	symbols       map[string]*atomic.Bool
 	fsymbolLevels map[string]time.Duration
-	market        map[string]*list.List
+	contracts     map[string]contract // COIN-M perpetuals by symbol
+	market        map[string]*history
+	pairs         map[string]pair
+	quotes        []string                   // tracked quote assets, the first one is the default
+	usdPrices     map[string]*atomic.Float64 // prices of the non-USD quote assets by symbol, e.g. BTCUSDT
 	funding       map[string]*atomic.Float64
 	alert         map[string]*alertdata
+	alertMu       sync.Mutex // guards the alert data written by the all markets stream
 	channel       map[string]*atomic.Bool
-	ignored       map[string]struct{} // not thread-safe
+	ignored       *keySet
+	defaultChats  []string // reached by the empty destination, e.g. "telegram:123"
+	snoozes       *timers
+	mutes         *timers
+	ignores       *timers
+	throttle      *throttle
+	watchlists    *watchlists
+	subscriptions *subscriptions
+	audit         *audit
+	profiles      *profiles
+	quiet         *quietHours
+	rules         *rules
+	reports       *reports
+	store         *record.Store
+	outcomes      *outcomes
+	webhook       *webhook.Sink
+	templates     *format.Templates
+	router        *command.Router
+	access        *command.Access
 
 	sRateThreshold    *atomic.Float64
 	fRateThreshold    *atomic.Float64
@@ -70,24 +101,95 @@ type BinanceFilter struct {
 	minQuoteThreshold *atomic.Float64
 	maxQuoteThreshold *atomic.Float64
 	windowThreshold   *atomic.Int64
+	priorityThreshold *atomic.Float64
+	retention         *atomic.Int64
 
 	stopCMarketsStatServe        chan struct{}
 	stopCCombinedTrade           chan struct{}
 	stopCFutureCombinedTrade     chan struct{}
 	stopCFutureCombinedMarkPrice chan struct{}
+	stopCDelivery                chan struct{} // closed to stop every COIN-M stream, swapped under deliveryMu
+	deliveryMu                   sync.Mutex
 	runningC                     chan struct{}
+	backfilling                  *atomic.Bool
 
-	futureFilter *atomic.String
-
-	postMessageBackend func(string)
+	postMessageBackend func(string, format.Message)
+	postPhotoBackend   func(string, format.Message, []byte)
 	printer            *message.Printer
 	localTime          *time.Location
+	logFormat          format.Format
 }
 
 // New create BinanceFilter
-func New(postMessage func(string), location string) *BinanceFilter {
+func New(postMessage func(string, format.Message), postPhoto func(string, format.Message, []byte), location string) *BinanceFilter {
+	localTime, _ := time.LoadLocation(location)
+	bf := newFilter(postMessage, postPhoto, localTime)
+
+	if err := bf.updateData(); err != nil {
+		panic(err)
+	}
+
+	if err := bf.restoreSnapshot(); err != nil {
+		log.Println(err)
+	}
+
+	bf.router = bf.newRouter()
+
+	if err := bf.loadAccess(); err != nil {
+		log.Println(err)
+	}
+
+	if err := bf.subscriptions.load(); err != nil {
+		log.Println(err)
+	}
+
+	if err := bf.audit.load(); err != nil {
+		log.Println(err)
+	}
+
+	if err := bf.rules.load(); err != nil {
+		log.Println(err)
+	}
+
+	if err := bf.reports.load(); err != nil {
+		log.Println(err)
+	}
+
+	if err := bf.watchlists.load(); err != nil {
+		log.Println(err)
+	}
+
+	if err := bf.profiles.load(); err != nil {
+		log.Println(err)
+	}
+
+	if err := bf.quiet.load(); err != nil {
+		log.Println(err)
+	}
+
+	if err := bf.loadTemplates(); err != nil {
+		log.Println(err)
+	}
+
+	if store, err := openStore(); err != nil {
+		log.Println(err)
+	} else {
+		bf.store = store
+	}
+
+	if sink, err := openWebhook(); err != nil {
+		log.Println(err)
+	} else {
+		bf.webhook = sink
+	}
+
+	return bf
+}
+
+// newFilter with the default thresholds and no symbol
+func newFilter(postMessage func(string, format.Message), postPhoto func(string, format.Message, []byte), localTime *time.Location) *BinanceFilter {
 	symbols := make(map[string]*atomic.Bool)
-	market := make(map[string]*list.List)
+	market := make(map[string]*history)
 	alert := make(map[string]*alertdata)
 	channel := map[string]*atomic.Bool{
 		UP:    atomic.NewBool(true),
@@ -99,14 +201,29 @@ func New(postMessage func(string), location string) *BinanceFilter {
 		ALL:   atomic.NewBool(true),
 		// SYSTEM: atomic.NewBool(true),
 	}
-	localTime, _ := time.LoadLocation(location)
 
 	bf := BinanceFilter{
-		symbols: symbols,
-		market:  market,
-		alert:   alert,
-		channel: channel,
-		ignored: make(map[string]struct{}),
+		symbols:       symbols,
+		market:        market,
+		pairs:         make(map[string]pair),
+		quotes:        quoteAssets(os.Getenv("QUOTE_ASSETS")),
+		usdPrices:     make(map[string]*atomic.Float64),
+		alert:         alert,
+		channel:       channel,
+		ignored:       newKeySet(),
+		snoozes:       newTimers(),
+		mutes:         newTimers(),
+		ignores:       newTimers(),
+		throttle:      newThrottle(time.Now().UnixMilli()),
+		watchlists:    newWatchlists(),
+		subscriptions: newSubscriptions(),
+		audit:         &audit{},
+		profiles:      newProfiles(),
+		quiet:         &quietHours{},
+		rules:         newRules(),
+		reports:       &reports{},
+		outcomes:      &outcomes{},
+		access:        command.NewAccess(),
 
 		sRateThreshold:    atomic.NewFloat64(5.0),
 		fRateThreshold:    atomic.NewFloat64(10.0),
@@ -118,33 +235,46 @@ func New(postMessage func(string), location string) *BinanceFilter {
 		largeSThreshold:   atomic.NewFloat64(1_000_000),
 		largeFThreshold:   atomic.NewFloat64(2_000_000),
 		windowThreshold:   atomic.NewInt64(2 * milliInMin),
+		priorityThreshold: atomic.NewFloat64(3.0),
+		retention:         atomic.NewInt64(defaultRetention),
 
 		stopCMarketsStatServe:        make(chan struct{}),
 		stopCCombinedTrade:           make(chan struct{}),
 		stopCFutureCombinedTrade:     make(chan struct{}),
 		stopCFutureCombinedMarkPrice: make(chan struct{}),
 		runningC:                     make(chan struct{}),
-
-		futureFilter: atomic.NewString(""),
+		backfilling:                  atomic.NewBool(false),
 
 		postMessageBackend: postMessage,
+		postPhotoBackend:   postPhoto,
 		printer:            message.NewPrinter(language.English),
 		localTime:          localTime,
+		logFormat:          format.Parse(os.Getenv("LOG_FORMAT")),
 	}
 
-	if err := bf.updateData(); err != nil {
-		panic(err)
-	}
+	bf.templates = format.NewTemplates(defaultTemplates, localTime, bf.short)
 
 	return &bf
 }
 
 // Start to filter Binance's events
 func (bf *BinanceFilter) Start() {
+	go bf.backfill()
 	go bf.handleWsAllMarketsStat()
 	go bf.handleWsFutureCombinedTrade()
 	go bf.handleWsFutureCombinedMarkPriceServeWithRate()
 	go bf.handleWsCombinedTrade()
+	bf.startDelivery()
+	go bf.handleThrottleReport()
+	go bf.handleReports()
+	go bf.handleProfiles()
+	go bf.handleExpiries()
+	go bf.handleQuietHours()
+	go bf.handleSnapshot()
+	go bf.handleOutcomes()
+	if bf.webhook != nil {
+		go bf.webhook.Start()
+	}
 
 	<-bf.runningC
 }
@@ -153,15 +283,22 @@ func (bf *BinanceFilter) handleWsAllMarketsStat() {
 	wsHandler := func(events binance.WsAllMarketsStatEvent) {
 		// start := time.Now()
 		for _, ev := range events {
+			if price, found := bf.usdPrices[ev.Symbol]; found {
+				if lastPrice, err := strconv.ParseFloat(ev.LastPrice, 64); err == nil {
+					price.Store(lastPrice)
+				}
+			}
+
 			if _, found := bf.market[ev.Symbol]; !found {
 				continue
 			}
 
-			if _, found := bf.ignored[ev.Symbol]; found {
+			if bf.ignored.has(ev.Symbol) {
 				continue
 			}
 
-			if ev.CloseTime/milliInSec <= bf.market[ev.Symbol].Back().Value.(*marketdata).Time/milliInSec {
+			history := bf.market[ev.Symbol]
+			if ev.CloseTime/milliInSec <= history.last().Time/milliInSec {
 				continue
 			}
 
@@ -180,77 +317,119 @@ func (bf *BinanceFilter) handleWsAllMarketsStat() {
 				continue
 			}
 
-			bf.market[ev.Symbol].Push(&marketdata{Price: askPrice, BaseVolume: baseVolume, QuoteVolume: quoteVolume, Time: ev.CloseTime})
-
-			if quoteVolume < bf.minQuoteThreshold.Load() || quoteVolume > bf.maxQuoteThreshold.Load() {
+			if askPrice == 0 {
 				continue
 			}
 
-			if ev.CloseTime < bf.alert[ev.Symbol].Time+bf.windowThreshold.Load() {
+			// the window excludes the current sample
+			minData, maxData, firstData, found := history.window(ev.CloseTime - bf.windowThreshold.Load())
+			history.push(marketdata{Price: askPrice, BaseVolume: baseVolume, QuoteVolume: quoteVolume, Time: ev.CloseTime}, bf.retention.Load())
+
+			usdVolume := bf.usd(ev.Symbol, quoteVolume)
+			if usdVolume < bf.threshold(ev.Symbol, "minvolume", bf.minQuoteThreshold) ||
+				usdVolume > bf.threshold(ev.Symbol, "maxvolume", bf.maxQuoteThreshold) {
 				continue
 			}
 
-			minElement, maxElement, firstElement := bf.market[ev.Symbol].MinAndMax(bf.compare(ev.CloseTime - bf.windowThreshold.Load()))
-			if minElement.Value.(*marketdata).Price == 0 || maxElement.Value.(*marketdata).Price == 0 {
+			if !found {
 				continue
 			}
 
-			minPrice := minElement.Value.(*marketdata).Price
+			minPrice := minData.Price
 			upRate := (askPrice - minPrice) * 100 / minPrice
-			maxPrice := maxElement.Value.(*marketdata).Price
+			maxPrice := maxData.Price
 			downRate := (askPrice - maxPrice) * 100 / maxPrice
 
-			if upRate >= 0 && upRate < bf.upThreshold.Load() ||
-				downRate < 0 && downRate > bf.downThreshold.Load() {
+			upThreshold := bf.threshold(ev.Symbol, "up", bf.upThreshold)
+			downThreshold := bf.threshold(ev.Symbol, "down", bf.downThreshold)
+			if upRate >= 0 && upRate < upThreshold ||
+				downRate < 0 && downRate > downThreshold {
 				continue
 			}
 
-			minVolume := firstElement.Value.(*marketdata).QuoteVolume
-			maxVolume := bf.market[ev.Symbol].Back().Value.(*marketdata).QuoteVolume
+			minVolume := firstData.QuoteVolume
+			maxVolume := quoteVolume
 			volumeRate := (maxVolume - minVolume) * 100 / minVolume
 
-			if volumeRate < bf.volumeThreshold.Load() {
+			if volumeRate < bf.threshold(ev.Symbol, "volume", bf.volumeThreshold) {
 				continue
 			}
 
-			var priceRate float64
+			// the subscriptions lower the detection, only the alerts passing the shared thresholds
+			// start the window of the default chat and count in the streaks
+			shared := bf.sharedThreshold(ev.Symbol)
+			sharedPass := (upRate >= shared("up") || downRate <= shared("down")) && volumeRate >= shared("volume")
+
+			var priceRate, threshold float64
 			var updown string
 			var updownNumber int
+			bf.alertMu.Lock()
+			data := bf.alert[ev.Symbol]
+			last := data.Time
+			if !sharedPass && data.SubTime > last {
+				last = data.SubTime
+			}
+			if ev.CloseTime < last+bf.windowThreshold.Load() {
+				bf.alertMu.Unlock()
+				continue
+			}
+
+			streak := func(number int) int {
+				if ev.CloseTime <= data.Time+2*bf.windowThreshold.Load() {
+					return number + 1
+				}
+				return 1
+			}
+
 			// UP
-			if upRate >= bf.upThreshold.Load() {
+			if upRate >= upThreshold {
 				priceRate = upRate
+				threshold = upThreshold
 				updown = "UP"
-				if ev.CloseTime <= bf.alert[ev.Symbol].Time+2*bf.windowThreshold.Load() {
-					bf.alert[ev.Symbol].UpNumber++
-				} else {
-					bf.alert[ev.Symbol].UpNumber = 1
+				updownNumber = streak(data.UpNumber)
+				if sharedPass {
+					data.UpNumber = updownNumber
 				}
-				updownNumber = bf.alert[ev.Symbol].UpNumber
 			}
 
 			// DOWN
-			if downRate <= bf.downThreshold.Load() {
+			if downRate <= downThreshold {
 				priceRate = downRate
+				threshold = downThreshold
 				updown = "DOWN"
-				if ev.CloseTime <= bf.alert[ev.Symbol].Time+2*bf.windowThreshold.Load() {
-					bf.alert[ev.Symbol].DownNumber++
-				} else {
-					bf.alert[ev.Symbol].DownNumber = 1
+				updownNumber = streak(data.DownNumber)
+				if sharedPass {
+					data.DownNumber = updownNumber
 				}
-				updownNumber = bf.alert[ev.Symbol].DownNumber
 			}
 
-			bf.alert[ev.Symbol].Time = ev.CloseTime
+			if sharedPass {
+				data.Time = ev.CloseTime
+			} else {
+				data.SubTime = ev.CloseTime
+			}
+			bf.alertMu.Unlock()
+
+			if bf.excluded(updown, ev.Symbol) {
+				continue
+			}
 
 			future := "S"
 			if bf.symbols[ev.Symbol].Load() {
 				future = "F"
 			}
-			msg := fmt.Sprintf("<b>#%s(%d) #%s(%s)</b>: <u>%4.2f-%4.2f</u> P: <u>%s</u> V: %s T: %s",
-				updown, updownNumber, ev.Symbol[:len(ev.Symbol)-4], future, priceRate, volumeRate, strconv.FormatFloat(askPrice, 'f', -1, 64),
-				bf.printer.Sprintf("%d", int64(quoteVolume)), time.Now().In(bf.localTime).Format("15:04:05 2006-01-02"))
-			log.Println(msg)
-			bf.postMessage(updown, msg)
+			symbol := ev.Symbol
+			msg := bf.templates.Alert(&record.Alert{Channel: updown, Symbol: symbol, Base: bf.baseOf(symbol), Quote: bf.quoteOf(symbol), Market: future,
+				Price: askPrice, Rate: priceRate, VolumeRate: volumeRate, Value: usdVolume, Number: updownNumber, Threshold: threshold, Time: ev.CloseTime})
+			log.Println(msg.Render(bf.logFormat))
+			bf.postAlert(msg, func() []byte {
+				points, minIndex, maxIndex := bf.chartPoints(symbol, firstData, minData, maxData)
+				img, err := chart.Render(points, minIndex, maxIndex)
+				if err != nil {
+					log.Println(err)
+				}
+				return img
+			})
 		}
 		// fmt.Printf("took %s\n", time.Since(start))
 	}
@@ -271,7 +450,7 @@ func (bf *BinanceFilter) handleWsCombinedTrade() {
 	wsCombinedTradeHandler := func(event *binance.WsCombinedTradeEvent) {
 		data := event.Data
 
-		if _, found := bf.ignored[data.Symbol]; found {
+		if bf.ignored.has(data.Symbol) {
 			return
 		}
 
@@ -285,32 +464,37 @@ func (bf *BinanceFilter) handleWsCombinedTrade() {
 			return
 		}
 
-		maketData := bf.market[data.Symbol].Back().Value.(*marketdata)
+		maketData := bf.market[data.Symbol].last()
 
-		if maketData.BaseVolume == 0 || maketData.QuoteVolume < bf.minQuoteThreshold.Load() || maketData.QuoteVolume > bf.maxQuoteThreshold.Load() {
+		if maketData.BaseVolume == 0 ||
+			bf.usd(data.Symbol, maketData.QuoteVolume) < bf.threshold(data.Symbol, "minvolume", bf.minQuoteThreshold) ||
+			bf.usd(data.Symbol, maketData.QuoteVolume) > bf.threshold(data.Symbol, "maxvolume", bf.maxQuoteThreshold) {
 			return
 		}
 
 		rate := quantity * 100 / maketData.BaseVolume
-		value := quantity * price
+		value := bf.usd(data.Symbol, quantity*price)
 		channel := BUY
-		if rate >= bf.sRateThreshold.Load() || value >= bf.largeSThreshold.Load() {
+		rateThreshold := bf.threshold(data.Symbol, "srate", bf.sRateThreshold)
+		largeThreshold := bf.threshold(data.Symbol, "slarge", bf.largeSThreshold)
+		if rate >= rateThreshold || value >= largeThreshold {
 			future := "S"
 			if bf.symbols[data.Symbol].Load() {
 				future = "F"
 			}
 
-			msg := fmt.Sprintf("<b>#BUY #%s(%s)</b>", data.Symbol[:len(data.Symbol)-4], future)
 			if data.IsBuyerMaker {
 				channel = SELL
-				msg = fmt.Sprintf("<b>#SELL #%s(%s)</b>", data.Symbol[:len(data.Symbol)-4], future)
 			}
 
-			msg = fmt.Sprintf("%s <u>%4.2f</u> P: <u>%s</u> V: %s Q: %s %s",
-				msg, rate, strconv.FormatFloat(price, 'f', -1, 64), bf.printer.Sprintf("%d", int(value)),
-				bf.printer.Sprintf("%d", int(quantity)), time.Now().In(bf.localTime).Format("15:04:05 2006-01-02"))
-			log.Println(msg)
-			bf.postMessage(channel, msg)
+			if bf.excluded(channel, data.Symbol) {
+				return
+			}
+
+			msg := bf.templates.Alert(&record.Alert{Channel: channel, Symbol: data.Symbol, Base: bf.baseOf(data.Symbol), Quote: bf.quoteOf(data.Symbol), Market: future,
+				Price: price, Rate: rate, Value: value, Quantity: quantity, Threshold: rateThreshold, Time: data.TradeTime})
+			log.Println(msg.Render(bf.logFormat))
+			bf.postAlert(msg, nil)
 		}
 	}
 
@@ -333,15 +517,7 @@ func (bf *BinanceFilter) handleWsCombinedTrade() {
 
 func (bf *BinanceFilter) handleWsFutureCombinedTrade() {
 	wsCombinedTradeHandler := func(event *futures.WsAggTradeEvent) {
-		if _, found := bf.ignored[event.Symbol]; found {
-			return
-		}
-
-		if strings.HasPrefix(event.Symbol, "BTC") || strings.HasPrefix(event.Symbol, "ETH") {
-			return
-		}
-
-		if bf.futureFilter.Load() != "" && !strings.HasPrefix(event.Symbol, bf.futureFilter.Load()) {
+		if bf.ignored.has(event.Symbol) {
 			return
 		}
 
@@ -355,28 +531,32 @@ func (bf *BinanceFilter) handleWsFutureCombinedTrade() {
 			return
 		}
 
-		maketData := bf.market[event.Symbol].Back().Value.(*marketdata)
+		maketData := bf.market[event.Symbol].last()
 
-		if maketData.BaseVolume == 0 || maketData.QuoteVolume < bf.minQuoteThreshold.Load() || maketData.QuoteVolume > bf.maxQuoteThreshold.Load() {
+		if maketData.BaseVolume == 0 ||
+			bf.usd(event.Symbol, maketData.QuoteVolume) < bf.threshold(event.Symbol, "minvolume", bf.minQuoteThreshold) ||
+			bf.usd(event.Symbol, maketData.QuoteVolume) > bf.threshold(event.Symbol, "maxvolume", bf.maxQuoteThreshold) {
 			return
 		}
 
 		rate := quantity * 100 / maketData.BaseVolume
-		value := quantity * price
+		value := bf.usd(event.Symbol, quantity*price)
 		channel := FBUY
-		if bf.futureFilter.Load() != "" ||
-			(bf.futureFilter.Load() == "" && rate >= bf.fRateThreshold.Load() || value >= bf.largeFThreshold.Load()) {
-			msg := fmt.Sprintf("<b>#FBUY #%s #R%d</b>", event.Symbol[:len(event.Symbol)-4], int(rate+0.5))
+		rateThreshold := bf.threshold(event.Symbol, "frate", bf.fRateThreshold)
+		largeThreshold := bf.threshold(event.Symbol, "flarge", bf.largeFThreshold)
+		if rate >= rateThreshold || value >= largeThreshold {
 			if event.Maker {
 				channel = FSELL
-				msg = fmt.Sprintf("<b>#FSELL #%s #R%d</b>", event.Symbol[:len(event.Symbol)-4], int(rate+0.5))
 			}
 
-			msg = fmt.Sprintf("%s <u>%4.2f</u> P: <u>%s</u> V: %s Q: %s %s",
-				msg, rate, strconv.FormatFloat(price, 'f', -1, 64), bf.printer.Sprintf("%d", int(value)),
-				bf.printer.Sprintf("%d", int(quantity)), time.Now().In(bf.localTime).Format("15:04:05 2006-01-02"))
-			log.Println(msg)
-			bf.postMessage(channel, msg)
+			if bf.excluded(channel, event.Symbol) {
+				return
+			}
+
+			msg := bf.templates.Alert(&record.Alert{Channel: channel, Symbol: event.Symbol, Base: bf.baseOf(event.Symbol), Quote: bf.quoteOf(event.Symbol), Market: "F",
+				Price: price, SpotPrice: maketData.Price, Rate: rate, Value: value, Quantity: quantity, Threshold: rateThreshold, Time: event.TradeTime})
+			log.Println(msg.Render(bf.logFormat))
+			bf.postAlert(msg, nil)
 		}
 	}
 
@@ -429,32 +609,14 @@ func (bf *BinanceFilter) handleWsFutureCombinedMarkPriceServeWithRate() {
 	<-doneC
 }
 
-func (bf *BinanceFilter) compare(t int64) func(a *list.Element, b *list.Element) int {
-	return func(a *list.Element, b *list.Element) int {
-		if a.Value.(*marketdata).Price*b.Value.(*marketdata).Price == 0 {
-			return -1
-		}
-
-		if a.Value.(*marketdata).Time < t {
-			return -1
-		}
-
-		if a.Value.(*marketdata).Price >= b.Value.(*marketdata).Price {
-			return 1
-		}
-
-		return 0
-	}
-}
-
 func (bf *BinanceFilter) updateData() error {
-	client := binance.NewClient(os.Getenv("BINANCE_API_KEY"), os.Getenv("BINANCE_SECRET_KEY"))
+	client := newClient()
 	res, err := client.NewExchangeInfoService().Symbols().Do(context.Background())
 	if err != nil {
 		return err
 	}
 
-	fclient := binance.NewFuturesClient(os.Getenv("BINANCE_API_KEY"), os.Getenv("BINANCE_SECRET_KEY"))
+	fclient := newFuturesClient()
 	fres, ferr := fclient.NewExchangeInfoService().Do(context.Background())
 	if ferr != nil {
 		return ferr
@@ -470,18 +632,10 @@ func (bf *BinanceFilter) updateData() error {
 	}
 
 	for _, e := range res.Symbols {
-		if !(strings.HasSuffix(e.Symbol, "USDT") /*|| strings.HasSuffix(e.Symbol, "USD") || strings.HasSuffix(e.Symbol, "BTC")*/) ||
-			strings.HasSuffix(e.Symbol, "USDUSDT") ||
-			strings.HasPrefix(e.Symbol, "USD") {
+		if !bf.addSymbol(e.Symbol, e.BaseAsset, e.QuoteAsset) {
 			continue
 		}
 
-		if _, found := bf.market[e.Symbol]; !found {
-			bf.market[e.Symbol] = list.NewList(60*60, &marketdata{Price: 0, BaseVolume: 0, QuoteVolume: 0, Time: 0})
-			bf.alert[e.Symbol] = &alertdata{Time: 0, UpNumber: 0, DownNumber: 0}
-			bf.symbols[e.Symbol] = atomic.NewBool(false)
-		}
-
 		if _, found := bf.fsymbolLevels[e.Symbol]; found && !bf.symbols[e.Symbol].Load() {
 			bf.symbols[e.Symbol].Store(true)
 		}
@@ -493,11 +647,145 @@ func (bf *BinanceFilter) updateData() error {
 		}
 	}
 
+	// the COIN-M perpetuals, traded against the spot market of their base, optional
+	bf.contracts = make(map[string]contract)
+	dres, err := newDeliveryClient().NewExchangeInfoService().Do(context.Background())
+	if err != nil {
+		log.Printf("COIN-M futures skipped: %v\n", err)
+		return nil
+	}
+	for _, e := range dres.Symbols {
+		if e.ContractType != "PERPETUAL" || e.ContractStatus != "TRADING" {
+			continue
+		}
+
+		c := contract{pair: e.Pair, size: float64(e.ContractSize)}
+		if spot, found := bf.symbolOf(e.BaseAsset); found {
+			c.spot = spot
+		}
+		bf.contracts[e.Symbol] = c
+		bf.funding[e.Symbol] = atomic.NewFloat64(0)
+	}
+
 	return nil
 }
 
+// addSymbol of the exchange info, reporting whether its quote asset is tracked
+func (bf *BinanceFilter) addSymbol(symbol string, base string, quote string) bool {
+	if !bf.addPair(symbol, base, quote) {
+		return false
+	}
+
+	if _, found := bf.market[symbol]; !found {
+		bf.market[symbol] = newHistory()
+		bf.alertMu.Lock()
+		bf.alert[symbol] = &alertdata{Time: 0, UpNumber: 0, DownNumber: 0}
+		bf.alertMu.Unlock()
+		bf.symbols[symbol] = atomic.NewBool(false)
+	}
+
+	return true
+}
+
+// postMessage of channel c written in HTML
 func (bf *BinanceFilter) postMessage(c string, s string) {
 	if c == SYSTEM || (bf.channel[ALL].Load() && bf.channel[c].Load()) {
-		bf.postMessageBackend(s)
+		msg := format.NewMessage(c, s)
+		bf.postMessageBackend("", msg)
+		bf.postWebhook(msg)
+	}
+}
+
+func (bf *BinanceFilter) postAlert(msg format.Message, photo func() []byte) {
+	// filtered and routed by the spot market, throttled and stored by contract for COIN-M
+	a := msg.Alert
+	c, symbol := a.Channel, bf.spotOf(a.Symbol)
+	if !bf.channel[ALL].Load() || !bf.channel[c].Load() {
+		return
+	}
+
+	if !bf.watchlists.allowed(c, symbol) {
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	if bf.snoozes.active(symbol, now) {
+		return
+	}
+
+	// the detection runs at the most sensitive thresholds of the subscriptions, only the alerts
+	// passing the shared ones are throttled as a whole, stored and sent to the default chat
+	shared := bf.sharedThreshold(symbol)
+	a.Priority = priority(a, shared)
+	high := a.Priority >= bf.priorityThreshold.Load()
+	msg.Silent = !high
+
+	recipients := []string{}
+	sent := map[string]struct{}{}
+	passed := passes(a, shared)
+	if passed {
+		if !bf.throttle.allow(c, a.Symbol, a.Priority, bf.priorityThreshold.Load(), now) {
+			return
+		}
+
+		if bf.store != nil {
+			if err := bf.store.Add(a); err != nil {
+				log.Println(err)
+			} else {
+				bf.outcomes.add(*a)
+			}
+		}
+
+		bf.postWebhook(msg)
+
+		// below the priority, alerts are deferred during the quiet hours and silent otherwise
+		if !high && bf.quiet.active(time.Now().In(bf.localTime)) {
+			bf.quiet.add(msg, a.Priority)
+		} else {
+			route := bf.watchlists.route(symbol)
+			recipients = append(recipients, route)
+			// the empty route already reaches the default chats
+			if route == "" {
+				for _, chat := range bf.defaultChats {
+					sent[chat] = struct{}{}
+				}
+			}
+		}
+	}
+
+	// the subscribers get the alerts passing their thresholds, throttled each below the shared ones
+	for _, chat := range bf.subscriptions.recipients(a, symbol, shared) {
+		if passed || bf.throttle.allowChat(chat, c, a.Symbol, now) {
+			recipients = append(recipients, chat)
+		}
+	}
+
+	var img []byte
+	if photo != nil && len(recipients) > 0 {
+		img = photo()
+	}
+
+	for _, to := range recipients {
+		if _, found := sent[to]; found {
+			continue
+		}
+		sent[to] = struct{}{}
+
+		if img != nil {
+			bf.postPhotoBackend(to, msg, img)
+		} else {
+			bf.postMessageBackend(to, msg)
+		}
+	}
+}
+
+func (bf *BinanceFilter) handleThrottleReport() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if suppressed := bf.throttle.rollover(now.UnixMilli()); len(suppressed) > 0 {
+			bf.postMessage(SYSTEM, formatSuppressed(suppressed))
+		}
 	}
 }
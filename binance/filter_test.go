@@ -0,0 +1,64 @@
+package filter
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"alertbot/chat"
+	"alertbot/format"
+)
+
+// posted records the messages and photos of a test filter by chat
+type posted struct {
+	mu       sync.Mutex
+	messages map[string][]format.Message
+}
+
+func (p *posted) post(to string, msg format.Message) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.messages[to] = append(p.messages[to], msg)
+}
+
+// to the chat, the default one being ""
+func (p *posted) to(chat string) []format.Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.messages[chat]
+}
+
+// newTestFilter without network nor persistence, tracking USDT symbols, e.g. "SOLUSDT"
+func newTestFilter(t *testing.T, symbols ...string) (*BinanceFilter, *posted) {
+	t.Setenv("DATA_LOCATION", t.TempDir())
+
+	p := &posted{messages: make(map[string][]format.Message)}
+	bf := newFilter(p.post, func(to string, msg format.Message, _ []byte) { p.post(to, msg) }, time.UTC)
+	for _, symbol := range symbols {
+		bf.addSymbol(symbol, strings.TrimSuffix(symbol, "USDT"), "USDT")
+	}
+	bf.router = bf.newRouter()
+
+	return bf, p
+}
+
+// run a command line, e.g. "/subscribe add SOL", sent by user from its own chat
+func run(t *testing.T, bf *BinanceFilter, user string, line string) error {
+	return runIn(t, bf, user, user, line)
+}
+
+// runIn run a command line sent by user in the chat from
+func runIn(t *testing.T, bf *BinanceFilter, from string, user string, line string) error {
+	name, payload, _ := strings.Cut(line, " ")
+	for _, cmd := range bf.router.Commands() {
+		if contains(cmd.Names, name) {
+			return bf.router.Run(cmd, chat.Request{Payload: payload, From: from, User: user})
+		}
+	}
+
+	t.Fatalf("no command %s", name)
+	return nil
+}
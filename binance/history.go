@@ -0,0 +1,319 @@
+package filter
+
+import (
+	"sort"
+	"sync"
+
+	"alertbot/utils/window"
+)
+
+const (
+	recentRetention  int64 = milliInHour
+	candleInterval   int64 = milliInMin
+	defaultRetention int64 = milliInDay
+	maxRetention     int64 = 2 * milliInDay
+)
+
+// candle is a downsampled interval of market data
+type candle struct {
+	Time        int64 // interval start
+	Open        float64
+	Low         float64
+	High        float64
+	Close       float64
+	BaseVolume  float64
+	QuoteVolume float64
+}
+
+func (c candle) time() int64 { return c.Time }
+
+func (c candle) low() float64 { return c.Low }
+
+func (c candle) high() float64 { return c.High }
+
+func (c candle) marketdata() marketdata {
+	return marketdata{Price: c.Close, BaseVolume: c.BaseVolume, QuoteVolume: c.QuoteVolume, Time: c.Time}
+}
+
+// history of a symbol keeps every sample of the last hour and one candle per minute
+// for the retention, queries are by time whatever the events rate
+type history struct {
+	mu      sync.Mutex
+	recent  *window.Window[marketdata]
+	candles *window.Window[candle]
+	pending candle
+}
+
+func newHistory() *history {
+	return &history{
+		recent:  window.New(int(recentRetention/milliInSec), marketdata.time, marketdata.price, marketdata.price),
+		candles: window.New(int(maxRetention/candleInterval), candle.time, candle.low, candle.high),
+	}
+}
+
+// push a sample newer than the last one, evicting data older than the retention
+func (h *history) push(d marketdata, retention int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.recent.Len() > 0 && d.Time <= h.recent.Back().Time {
+		return
+	}
+
+	h.recent.Evict(d.Time - recentRetention)
+	h.recent.Push(d)
+
+	start := d.Time - d.Time%candleInterval
+	if h.pending.Time != start {
+		if h.pending.Time != 0 {
+			h.candles.Push(h.pending)
+		}
+		h.pending = candle{Time: start, Open: d.Price, Low: d.Price, High: d.Price}
+	}
+	if d.Price < h.pending.Low {
+		h.pending.Low = d.Price
+	}
+	if d.Price > h.pending.High {
+		h.pending.High = d.Price
+	}
+	h.pending.Close, h.pending.BaseVolume, h.pending.QuoteVolume = d.Price, d.BaseVolume, d.QuoteVolume
+
+	h.candles.Evict(d.Time - retention)
+}
+
+// last sample or the zero value
+func (h *history) last() marketdata {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.recent.Back()
+}
+
+// window returns the min, max and first samples since from,
+// ok is false unless the history reaches back before from
+func (h *history) window(from int64) (min marketdata, max marketdata, first marketdata, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.recent.Len() == 0 || h.recent.Front().Time >= from {
+		return min, max, first, false
+	}
+
+	return h.recent.Range(from)
+}
+
+// span returns the first, low, high and last prices since from, exact within the last hour
+// and at candle resolution before, and the time of the first sample
+func (h *history) span(from int64) (first float64, low float64, high float64, last float64, since int64, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.recent.Len() == 0 {
+		return 0, 0, 0, 0, 0, false
+	}
+
+	last = h.recent.Back().Price
+	if h.recent.Front().Time <= from {
+		minData, maxData, firstData, ok := h.recent.Range(from)
+		return firstData.Price, minData.Price, maxData.Price, last, firstData.Time, ok
+	}
+
+	minData, maxData, firstData, _ := h.recent.Range(h.recent.Front().Time)
+	first, low, high, since = firstData.Price, minData.Price, maxData.Price, firstData.Time
+
+	if minCandle, maxCandle, firstCandle, ok := h.candles.Range(from - candleInterval + 1); ok && firstCandle.Time < since {
+		first, since = firstCandle.Open, firstCandle.Time
+		if minCandle.Low < low {
+			low = minCandle.Low
+		}
+		if maxCandle.High > high {
+			high = maxCandle.High
+		}
+	}
+
+	return first, low, high, last, since, true
+}
+
+// samples since from, candle closes before the last hour
+func (h *history) samples(from int64) []marketdata {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ret := []marketdata{}
+	recentFrom := int64(0)
+	if h.recent.Len() > 0 {
+		recentFrom = h.recent.Front().Time
+	}
+
+	if from < recentFrom {
+		for i := h.candles.Search(from); i < h.candles.Len(); i++ {
+			if c := h.candles.At(i); c.Time+candleInterval <= recentFrom {
+				ret = append(ret, c.marketdata())
+			}
+		}
+	}
+
+	for i := h.recent.Search(from); i < h.recent.Len(); i++ {
+		ret = append(ret, h.recent.At(i))
+	}
+
+	return ret
+}
+
+// covers reports whether the history reaches back to from, by its samples or,
+// once restored from a snapshot, by candles up to them without a missing minute
+func (h *history) covers(from int64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.recent.Len() == 0 {
+		return false
+	}
+	if h.recent.Front().Time <= from {
+		return true
+	}
+
+	covered := make(map[int64]struct{})
+	for i := h.candles.Search(from - candleInterval + 1); i < h.candles.Len(); i++ {
+		covered[h.candles.At(i).Time] = struct{}{}
+	}
+	if h.pending.Time != 0 {
+		covered[h.pending.Time] = struct{}{}
+	}
+	for i := 0; i < h.recent.Len(); i++ {
+		t := h.recent.At(i).Time
+		covered[t-t%candleInterval] = struct{}{}
+	}
+
+	last := h.recent.Back().Time
+	for t := from - from%candleInterval; t <= last; t += candleInterval {
+		if _, found := covered[t]; !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// backfill merge closed candles into the minutes without data, before the history
+// or since the snapshot it was restored from, their closes also fill the last hour
+func (h *history) backfill(candles []candle, retention int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	covered := make(map[int64]struct{})
+	for i := 0; i < h.candles.Len(); i++ {
+		covered[h.candles.At(i).Time] = struct{}{}
+	}
+	for i := 0; i < h.recent.Len(); i++ {
+		t := h.recent.At(i).Time
+		covered[t-t%candleInterval] = struct{}{}
+	}
+	if h.pending.Time != 0 {
+		covered[h.pending.Time] = struct{}{}
+	}
+
+	missing := []candle{}
+	for _, c := range candles {
+		if _, found := covered[c.Time]; !found {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	merged := append([]candle{}, missing...)
+	for i := 0; i < h.candles.Len(); i++ {
+		merged = append(merged, h.candles.At(i))
+	}
+	// a pending candle followed by klines is closed
+	if h.pending.Time != 0 && h.pending.Time < missing[len(missing)-1].Time {
+		merged, h.pending = append(merged, h.pending), candle{}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Time < merged[j].Time })
+
+	samples := []marketdata{}
+	for _, c := range missing {
+		close := c.marketdata()
+		close.Time = c.Time + candleInterval - 1
+		samples = append(samples, close)
+	}
+	for i := 0; i < h.recent.Len(); i++ {
+		samples = append(samples, h.recent.At(i))
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Time < samples[j].Time })
+
+	latest := samples[len(samples)-1].Time
+	h.recent.Reset()
+	h.candles.Reset()
+	for _, c := range merged {
+		if c.Time >= latest-retention {
+			h.candles.Push(c)
+		}
+	}
+	for _, d := range samples {
+		if d.Time >= latest-recentRetention {
+			h.recent.Push(d)
+		}
+	}
+}
+
+// historySnapshot is the persisted form of a history
+type historySnapshot struct {
+	Recent  []marketdata
+	Candles []candle
+	Pending candle
+}
+
+// snapshot of the candles and the samples of the last tail milliseconds
+func (h *history) snapshot(tail int64) historySnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ret := historySnapshot{
+		Recent:  []marketdata{},
+		Candles: make([]candle, h.candles.Len()),
+		Pending: h.pending,
+	}
+	for i := h.recent.Search(h.recent.Back().Time - tail); i < h.recent.Len(); i++ {
+		ret.Recent = append(ret.Recent, h.recent.At(i))
+	}
+	for i := range ret.Candles {
+		ret.Candles[i] = h.candles.At(i)
+	}
+
+	return ret
+}
+
+// restore replace the history with a snapshot
+func (h *history) restore(s historySnapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.recent.Reset()
+	h.candles.Reset()
+	for _, d := range s.Recent {
+		h.recent.Push(d)
+	}
+	for _, c := range s.Candles {
+		h.candles.Push(c)
+	}
+	h.pending = s.Pending
+}
+
+// priceAt returns the latest price at or before t
+func (h *history) priceAt(t int64) (float64, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if i := h.recent.Search(t + 1); i > 0 {
+		return h.recent.At(i - 1).Price, true
+	}
+
+	if i := h.candles.Search(t - candleInterval + 2); i > 0 {
+		return h.candles.At(i - 1).Close, true
+	}
+
+	return 0, false
+}
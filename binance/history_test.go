@@ -0,0 +1,197 @@
+package filter
+
+import (
+	"math"
+	"testing"
+)
+
+// start of the test histories, aligned to a minute
+const historyStart = int64(1_000_000) * milliInMin
+
+func testPrice(i int) float64 { return 100 + float64(i%7) - float64(i%3) }
+
+// pushSamples every step for a duration, returning the samples pushed
+func pushSamples(h *history, step int64, duration int64) []marketdata {
+	pushed := []marketdata{}
+	for i := 0; int64(i)*step < duration; i++ {
+		d := marketdata{Price: testPrice(i), BaseVolume: float64(i), QuoteVolume: float64(100 * i), Time: historyStart + int64(i)*step}
+		h.push(d, defaultRetention)
+		pushed = append(pushed, d)
+	}
+	return pushed
+}
+
+func TestHistoryCandles(t *testing.T) {
+	h := newHistory()
+	pushed := pushSamples(h, 10*milliInSec, 5*milliInMin)
+
+	// out of order samples are ignored
+	h.push(marketdata{Price: 1, Time: historyStart}, defaultRetention)
+	if last := h.last(); last != pushed[len(pushed)-1] {
+		t.Fatalf("last = %v, want %v", last, pushed[len(pushed)-1])
+	}
+
+	// the 4 closed minutes are candles, the fifth is pending
+	if h.candles.Len() != 4 {
+		t.Fatalf("%d candles, want 4", h.candles.Len())
+	}
+	for m := 0; m < 4; m++ {
+		minute := pushed[6*m : 6*m+6]
+		want := candle{Time: historyStart + int64(m)*milliInMin, Open: minute[0].Price, Low: math.Inf(1), High: math.Inf(-1),
+			Close: minute[5].Price, BaseVolume: minute[5].BaseVolume, QuoteVolume: minute[5].QuoteVolume}
+		for _, d := range minute {
+			want.Low, want.High = math.Min(want.Low, d.Price), math.Max(want.High, d.Price)
+		}
+		if got := h.candles.At(m); got != want {
+			t.Errorf("candle %d = %+v, want %+v", m, got, want)
+		}
+	}
+}
+
+func TestHistoryWindow(t *testing.T) {
+	h := newHistory()
+	pushed := pushSamples(h, 10*milliInSec, 10*milliInMin)
+
+	if _, _, _, ok := h.window(historyStart); ok {
+		t.Error("window from the first sample is ok, the history must reach back before it")
+	}
+
+	from := historyStart + 3*milliInMin + 5*milliInSec
+	min, max, first, ok := h.window(from)
+	if !ok {
+		t.Fatal("window not ok")
+	}
+
+	low, high := math.Inf(1), math.Inf(-1)
+	var want marketdata
+	for _, d := range pushed {
+		if d.Time < from {
+			continue
+		}
+		if want.Time == 0 {
+			want = d
+		}
+		low, high = math.Min(low, d.Price), math.Max(high, d.Price)
+	}
+	if min.Price != low || max.Price != high || first != want {
+		t.Errorf("window = %v %v %v, want low %v high %v first %v", min, max, first, low, high, want)
+	}
+}
+
+func TestHistorySpan(t *testing.T) {
+	h := newHistory()
+	pushed := pushSamples(h, 10*milliInSec, 3*milliInHour)
+	now := pushed[len(pushed)-1].Time
+
+	tests := []struct {
+		name    string
+		from    int64
+		candles bool // before the last hour, covered from the minute of from
+	}{
+		{"exact within the last hour", now - 30*milliInMin + 5*milliInSec, false},
+		{"candles before the last hour", now - 2*milliInHour + 25*milliInSec, true},
+	}
+
+	for _, tt := range tests {
+		first, low, high, last, since, ok := h.span(tt.from)
+		if !ok {
+			t.Fatalf("%s: span not ok", tt.name)
+		}
+
+		covered := tt.from
+		if tt.candles {
+			covered -= covered % milliInMin
+		}
+		wantLow, wantHigh, wantFirst, wantSince := math.Inf(1), math.Inf(-1), 0.0, int64(0)
+		for _, d := range pushed {
+			if d.Time < covered {
+				continue
+			}
+			if wantSince == 0 {
+				wantFirst, wantSince = d.Price, d.Time
+			}
+			wantLow, wantHigh = math.Min(wantLow, d.Price), math.Max(wantHigh, d.Price)
+		}
+
+		if first != wantFirst || low != wantLow || high != wantHigh || last != pushed[len(pushed)-1].Price || since != wantSince {
+			t.Errorf("%s: span = %v %v %v %v %d, want %v %v %v %v %d", tt.name,
+				first, low, high, last, since, wantFirst, wantLow, wantHigh, pushed[len(pushed)-1].Price, wantSince)
+		}
+	}
+}
+
+func TestHistorySamplesAndPriceAt(t *testing.T) {
+	h := newHistory()
+	pushed := pushSamples(h, 10*milliInSec, 2*milliInHour)
+	recentFrom := h.recent.Front().Time
+
+	samples := h.samples(historyStart)
+	for i := 1; i < len(samples); i++ {
+		if samples[i].Time <= samples[i-1].Time {
+			t.Fatalf("samples not in time order at %d: %d after %d", i, samples[i].Time, samples[i-1].Time)
+		}
+	}
+	// candle closes up to the last hour, then every sample
+	wantCandles := int((recentFrom - historyStart) / milliInMin)
+	if got := len(samples) - h.recent.Len(); got != wantCandles {
+		t.Errorf("%d candle closes, want %d", got, wantCandles)
+	}
+
+	tests := []struct {
+		t    int64
+		want float64
+		ok   bool
+	}{
+		{historyStart - 1, 0, false},
+		// within the last hour, the sample at or before t
+		{recentFrom + 15*milliInSec, pushed[(recentFrom+10*milliInSec-historyStart)/(10*milliInSec)].Price, true},
+		// before, the close of the last minute ended by t
+		{historyStart + 2*milliInMin + 30*milliInSec, pushed[11].Price, true},
+	}
+	for _, tt := range tests {
+		got, ok := h.priceAt(tt.t)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("priceAt(%d) = %v %v, want %v %v", tt.t, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestHistoryBackfill(t *testing.T) {
+	h := newHistory()
+	// the live history starts 10 minutes after historyStart
+	for i := 0; i < 60; i++ {
+		h.push(marketdata{Price: 200, Time: historyStart + 10*milliInMin + int64(i)*10*milliInSec}, defaultRetention)
+	}
+
+	// klines of the first 15 minutes, the last 5 overlap the live history
+	klines := []candle{}
+	for m := int64(0); m < 15; m++ {
+		klines = append(klines, candle{Time: historyStart + m*milliInMin, Open: 100, Low: 99, High: 101, Close: 100 + float64(m)})
+	}
+	h.backfill(klines, defaultRetention)
+
+	if h.candles.Front().Time != historyStart || h.candles.Front().Close != 100 {
+		t.Errorf("first candle = %+v, want the first kline", h.candles.Front())
+	}
+	for i := 1; i < h.candles.Len(); i++ {
+		if h.candles.At(i).Time <= h.candles.At(i-1).Time {
+			t.Fatalf("candles not in time order at %d", i)
+		}
+	}
+	for i := 0; i < h.candles.Len(); i++ {
+		if c := h.candles.At(i); c.Time >= historyStart+10*milliInMin && c.Close != 200 {
+			t.Errorf("overlapping kline %+v merged", c)
+		}
+	}
+
+	// the kline closes fill the last hour before the live samples
+	if h.recent.Front().Time != historyStart+milliInMin-1 || h.recent.Front().Price != 100 {
+		t.Errorf("first recent sample = %+v, want the close of the first kline", h.recent.Front())
+	}
+	if !h.covers(historyStart + milliInMin) {
+		t.Error("backfilled history does not cover its second minute")
+	}
+	if got, _ := h.priceAt(historyStart + 5*milliInMin); got != 104 {
+		t.Errorf("priceAt in the backfill = %v, want 104", got)
+	}
+}
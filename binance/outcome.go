@@ -0,0 +1,189 @@
+package filter
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"alertbot/record"
+)
+
+// horizons of the forward returns recorded after an alert
+var horizons = []struct {
+	name     string
+	duration int64
+}{
+	{"1m", milliInMin},
+	{"5m", 5 * milliInMin},
+	{"15m", 15 * milliInMin},
+	{"60m", 60 * milliInMin},
+}
+
+// outcomes keeps the alerts waiting for their forward returns
+type outcomes struct {
+	mu      sync.Mutex
+	pending []record.Alert
+}
+
+func (o *outcomes) add(a record.Alert) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.pending = append(o.pending, a)
+}
+
+func (bf *BinanceFilter) handleOutcomes() {
+	if bf.store == nil {
+		return
+	}
+
+	// resume the alerts of the last hour after a restart
+	last := horizons[len(horizons)-1].duration
+	if err := bf.store.Each(record.Query{From: time.Now().UnixMilli() - last - milliInMin}, func(a *record.Alert) error {
+		if len(a.Returns) < len(horizons) {
+			bf.outcomes.add(*a)
+		}
+		return nil
+	}); err != nil {
+		log.Println(err)
+	}
+
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		bf.followUp(now.UnixMilli())
+	}
+}
+
+// followUp record the returns of the elapsed horizons and drop the completed alerts
+func (bf *BinanceFilter) followUp(now int64) {
+	bf.outcomes.mu.Lock()
+	defer bf.outcomes.mu.Unlock()
+
+	pending := bf.outcomes.pending[:0]
+	for _, a := range bf.outcomes.pending {
+		history, found := bf.market[bf.spotOf(a.Symbol)]
+		price := reference(a)
+		if !found || price == 0 {
+			continue
+		}
+
+		updated := false
+		for _, h := range horizons {
+			if _, found := a.Returns[h.name]; found || now < a.Time+h.duration {
+				continue
+			}
+
+			later, found := history.priceAt(a.Time + h.duration)
+			if !found {
+				continue
+			}
+
+			if a.Returns == nil {
+				a.Returns = make(map[string]float64)
+			}
+			a.Returns[h.name] = (later - price) * 100 / price
+			updated = true
+		}
+
+		if updated {
+			if err := bf.store.Update(&a); err != nil {
+				log.Println(err)
+			}
+		}
+
+		if len(a.Returns) < len(horizons) && now < a.Time+horizons[len(horizons)-1].duration+5*milliInMin {
+			pending = append(pending, a)
+		}
+	}
+	bf.outcomes.pending = pending
+}
+
+// reference price of the returns of an alert, as they are measured on the spot market
+// the futures trades refer to the spot price when they alerted, zero before it was recorded
+func reference(a record.Alert) float64 {
+	if a.Channel == FBUY || a.Channel == FSELL {
+		return a.SpotPrice
+	}
+	return a.Price
+}
+
+// direction of the move expected by a channel
+func direction(channel string) float64 {
+	switch channel {
+	case DOWN, SELL, FSELL:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// performance summarize the forward returns of alerts by channel and threshold
+func (bf *BinanceFilter) performance(q record.Query) (string, error) {
+	type key struct {
+		channel   string
+		threshold float64
+	}
+	type stats struct {
+		count int
+		hits  map[string]int
+		moves map[string]float64
+		n     map[string]int
+	}
+
+	groups := make(map[key]*stats)
+	if err := bf.store.Each(q, func(a *record.Alert) error {
+		k := key{channel: a.Channel, threshold: a.Threshold}
+		if _, found := groups[k]; !found {
+			groups[k] = &stats{hits: make(map[string]int), moves: make(map[string]float64), n: make(map[string]int)}
+		}
+
+		g := groups[k]
+		g.count++
+		for name, r := range a.Returns {
+			move := r * direction(a.Channel)
+			g.n[name]++
+			g.moves[name] += move
+			if move > 0 {
+				g.hits[name]++
+			}
+		}
+		return nil
+	}); err != nil {
+		return "", err
+	}
+
+	if len(groups) == 0 {
+		return "no alert", nil
+	}
+
+	keys := make([]key, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].channel == keys[j].channel {
+			return keys[i].threshold < keys[j].threshold
+		}
+		return keys[i].channel < keys[j].channel
+	})
+
+	lines := []string{}
+	for _, k := range keys {
+		g := groups[k]
+		lines = append(lines, fmt.Sprintf("<b>#%s</b> threshold %0.2f: %d alert(s)", k.channel, k.threshold, g.count))
+		for _, h := range horizons {
+			if g.n[h.name] == 0 {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s: hit %0.0f%% avg %+0.2f%% (%d)", h.name,
+				float64(g.hits[h.name])*100/float64(g.n[h.name]), g.moves[h.name]/float64(g.n[h.name]), g.n[h.name]))
+		}
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,52 @@
+package filter
+
+import (
+	"path/filepath"
+	"testing"
+
+	"alertbot/record"
+)
+
+func TestFollowUp(t *testing.T) {
+	bf, _ := newTestFilter(t, "SOLUSDT")
+	store, err := record.Open(filepath.Join(t.TempDir(), "alerts.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	bf.store = store
+	bf.contracts = map[string]contract{"SOLUSD_PERP": {spot: "SOLUSDT", pair: "SOLUSD", size: 10}}
+
+	// the spot price goes from 100 to 110 in a minute
+	for i := int64(0); i <= 12; i++ {
+		bf.market["SOLUSDT"].push(marketdata{Price: 100 + float64(i)*10/6, Time: historyStart + i*10*milliInSec}, defaultRetention)
+	}
+
+	alerts := []*record.Alert{
+		{Channel: BUY, Symbol: "SOLUSDT", Market: "S", Price: 100, Time: historyStart},
+		// futures trade at a premium, measured from the spot price
+		{Channel: FBUY, Symbol: "SOLUSDT", Market: "F", Price: 104, SpotPrice: 100, Time: historyStart},
+		{Channel: FBUY, Symbol: "SOLUSD_PERP", Market: coinMarket, Price: 104, SpotPrice: 100, Time: historyStart},
+		// stored before the spot price, no return
+		{Channel: FSELL, Symbol: "SOLUSDT", Market: "F", Price: 104, Time: historyStart},
+	}
+	for _, a := range alerts {
+		if err := store.Add(a); err != nil {
+			t.Fatal(err)
+		}
+		bf.outcomes.add(*a)
+	}
+
+	bf.followUp(historyStart + 2*milliInMin)
+
+	found, err := store.Find(record.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, a := range found {
+		want, ok := 10.0, i < 3
+		if got, found := a.Returns["1m"]; found != ok || ok && got != want {
+			t.Errorf("%s %s 1m return = %v %v, want %v %v", a.Channel, a.Symbol, got, found, want, ok)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package filter
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"alertbot/command"
+)
+
+const (
+	profileStateFile = "profiles.json"
+	scheduleUser     = "schedule"
+)
+
+// profile bundles the thresholds of /set and the muted channels
+type profile struct {
+	Thresholds map[string]float64
+	Muted      []string `json:",omitempty"`
+}
+
+// String of a profile, e.g. "down=-5 up=2 muted=FSELL,UP", parsed back by parseProfile
+func (p profile) String() string {
+	ret := []string{}
+	for key, value := range p.Thresholds {
+		ret = append(ret, key+"="+strconv.FormatFloat(value, 'f', -1, 64))
+	}
+	sort.Strings(ret)
+	if len(p.Muted) > 0 {
+		ret = append(ret, "muted="+strings.Join(p.Muted, ","))
+	}
+
+	return strings.Join(ret, " ")
+}
+
+func parseProfile(s string) (profile, error) {
+	ret := profile{Thresholds: make(map[string]float64)}
+	for _, field := range strings.Fields(s) {
+		key, value, _ := strings.Cut(field, "=")
+		if key == "muted" {
+			ret.Muted = strings.Split(value, ",")
+			continue
+		}
+
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return ret, fmt.Errorf("wrong profile %q", field)
+		}
+		ret.Thresholds[key] = threshold
+	}
+
+	return ret, nil
+}
+
+// profileSchedule loads a profile daily at a local time
+type profileSchedule struct {
+	At      string // 15:04 in local time
+	Profile string
+}
+
+// profiles keeps the persisted profiles and their schedules
+type profiles struct {
+	mu        sync.Mutex
+	Profiles  map[string]profile
+	Schedules []profileSchedule
+}
+
+func newProfiles() *profiles {
+	return &profiles{Profiles: make(map[string]profile)}
+}
+
+func (p *profiles) load() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return loadState(profileStateFile, p)
+}
+
+func (p *profiles) save(name string, pr profile) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Profiles[name] = pr
+	return saveState(profileStateFile, p)
+}
+
+func (p *profiles) get(name string) (profile, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pr, found := p.Profiles[name]
+	return pr, found
+}
+
+// remove a profile and its schedules
+func (p *profiles) remove(name string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, found := p.Profiles[name]; !found {
+		return false, nil
+	}
+
+	delete(p.Profiles, name)
+	schedules := []profileSchedule{}
+	for _, s := range p.Schedules {
+		if s.Profile != name {
+			schedules = append(schedules, s)
+		}
+	}
+	p.Schedules = schedules
+
+	return true, saveState(profileStateFile, p)
+}
+
+// schedule a profile at a time, replacing the one at the same time, an empty profile unschedules it
+func (p *profiles) schedule(at string, name string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, found := p.Profiles[name]; !found && name != "" {
+		return false, nil
+	}
+
+	schedules := []profileSchedule{}
+	for _, s := range p.Schedules {
+		if s.At != at {
+			schedules = append(schedules, s)
+		}
+	}
+	if name != "" {
+		schedules = append(schedules, profileSchedule{At: at, Profile: name})
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].At < schedules[j].At })
+	p.Schedules = schedules
+
+	return true, saveState(profileStateFile, p)
+}
+
+// due profile at a local time, empty if none
+func (p *profiles) due(now time.Time) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, s := range p.Schedules {
+		if now.Format("15:04") == s.At {
+			return s.Profile
+		}
+	}
+
+	return ""
+}
+
+func (p *profiles) String() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	names := make([]string, 0, len(p.Profiles))
+	for name := range p.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{}
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("%s: %s", name, p.Profiles[name]))
+	}
+	for _, s := range p.Schedules {
+		lines = append(lines, fmt.Sprintf("at %s load %s", s.At, s.Profile))
+	}
+
+	if len(lines) == 0 {
+		return "no profile"
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// currentProfile of the thresholds and the muted channels
+func (bf *BinanceFilter) currentProfile() profile {
+	ret := profile{Thresholds: make(map[string]float64, len(configurable)), Muted: strings.Fields(bf.muted())}
+	for _, key := range configurable {
+		ret.Thresholds[key] = bf.configuration(key)
+	}
+
+	return ret
+}
+
+// applyProfile set its thresholds and mute its channels only, nothing unless every threshold is valid
+func (bf *BinanceFilter) applyProfile(p profile) error {
+	for _, key := range configurable {
+		if value, found := p.Thresholds[key]; found {
+			if err := validateConfiguration(key, value); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+	}
+
+	for _, key := range configurable {
+		if value, found := p.Thresholds[key]; found {
+			bf.setConfiguration(key, value)
+		}
+	}
+
+	bf.setMuted(p.Muted)
+
+	return nil
+}
+
+// setMuted mute the channels only, until unmuted as their mute timers are dropped
+func (bf *BinanceFilter) setMuted(muted []string) {
+	for channel, enabled := range bf.channel {
+		enabled.Store(!contains(muted, channel))
+		bf.mutes.set(channel, 0)
+	}
+}
+
+// loadProfile apply a saved profile, recorded for /undo
+func (bf *BinanceFilter) loadProfile(user string, name string) error {
+	p, found := bf.profiles.get(name)
+	if !found {
+		return errNotFound
+	}
+
+	before := bf.currentProfile()
+	if err := bf.applyProfile(p); err != nil {
+		return err
+	}
+	bf.recordChange(user, "profile", name, before.String(), bf.currentProfile().String())
+
+	return nil
+}
+
+func (bf *BinanceFilter) handleProfiles() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		name := bf.profiles.due(now.In(bf.localTime))
+		if name == "" {
+			continue
+		}
+
+		if err := bf.loadProfile(scheduleUser, name); err != nil {
+			log.Printf("Failed to load profile %s: %v\n", name, err)
+			continue
+		}
+		bf.postMessage(SYSTEM, fmt.Sprintf("profile %s loaded", name))
+	}
+}
+
+// Profiles list the profiles and their schedules
+func (bf *BinanceFilter) Profiles(c *command.Context) {
+	c.Reply(bf.profiles.String())
+}
+
+// ProfileSave save the thresholds and the muted channels as a profile
+func (bf *BinanceFilter) ProfileSave(c *command.Context) {
+	name := strings.ToLower(c.String("name"))
+	p := bf.currentProfile()
+	if err := bf.profiles.save(name, p); err != nil {
+		c.Reply(err.Error())
+		return
+	}
+
+	c.Reply(fmt.Sprintf("%s: %s", name, p))
+}
+
+// ProfileLoad apply a profile
+func (bf *BinanceFilter) ProfileLoad(c *command.Context) {
+	name := strings.ToLower(c.String("name"))
+	if err := bf.loadProfile(c.Request.User, name); err != nil {
+		c.Reply(fmt.Sprintf("%s %v", name, err))
+		return
+	}
+
+	c.Reply(fmt.Sprintf("profile %s loaded", name))
+}
+
+// ProfileRemove remove a profile and its schedules
+func (bf *BinanceFilter) ProfileRemove(c *command.Context) {
+	found, err := bf.profiles.remove(strings.ToLower(c.String("name")))
+	if err != nil {
+		c.Reply(err.Error())
+	} else if !found {
+		c.Reply("not found")
+	} else {
+		c.Reply("removed")
+	}
+}
+
+// ProfileSchedule load a profile daily at a local time, e.g. "22:00 quiet", "22:00 off" to unschedule
+func (bf *BinanceFilter) ProfileSchedule(c *command.Context) {
+	at, err := time.Parse("15:04", c.String("time"))
+	if err != nil {
+		c.Invalid("time", "not a time, e.g. 22:00")
+		return
+	}
+
+	name := strings.ToLower(c.String("name"))
+	if name == "off" {
+		name = ""
+	}
+
+	found, err := bf.profiles.schedule(at.Format("15:04"), name)
+	if err != nil {
+		c.Reply(err.Error())
+		return
+	}
+	if !found {
+		c.Reply(fmt.Sprintf("%s not found", name))
+		return
+	}
+
+	c.Reply(bf.profiles.String())
+}
@@ -0,0 +1,53 @@
+package filter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadProfileAllOrNothing(t *testing.T) {
+	bf, _ := newTestFilter(t)
+	bf.setConfiguration("up", 2)
+	bf.setConfiguration("down", -2)
+	bf.profiles.save("broken", profile{Thresholds: map[string]float64{"up": 5, "down": 3}, Muted: []string{UP}})
+
+	if err := bf.loadProfile("alice", "broken"); err == nil {
+		t.Fatal("profile with a positive down loaded")
+	}
+	if up := bf.configuration("up"); up != 2 {
+		t.Errorf("up = %v after a failed load, want 2", up)
+	}
+	if muted := bf.muted(); muted != "" {
+		t.Errorf("muted %q after a failed load", muted)
+	}
+	if changes := bf.audit.list(10); len(changes) != 0 {
+		t.Errorf("failed load audited %v", changes)
+	}
+
+	bf.profiles.save("quiet", profile{Thresholds: map[string]float64{"up": 5, "down": -5}, Muted: []string{UP}})
+	if err := bf.loadProfile("alice", "quiet"); err != nil {
+		t.Fatal(err)
+	}
+	if up, down := bf.configuration("up"), bf.configuration("down"); up != 5 || down != -5 {
+		t.Errorf("up, down = %v, %v, want 5, -5", up, down)
+	}
+	if changes := bf.audit.list(10); len(changes) != 1 || changes[0].Action != "profile" {
+		t.Errorf("audit = %v, want the profile load", changes)
+	}
+}
+
+func TestProfileDropsMuteTimers(t *testing.T) {
+	bf, _ := newTestFilter(t)
+	now := time.Now().UnixMilli()
+	bf.channel[UP].Store(false)
+	bf.mutes.set(UP, now+milliInMin)
+
+	bf.applyProfile(profile{Muted: []string{UP}})
+
+	if lapsed := bf.mutes.lapsed(now + milliInHour); len(lapsed) != 0 {
+		t.Errorf("mute timers %v left after the profile muted the channel", lapsed)
+	}
+	if bf.channel[UP].Load() {
+		t.Error("UP not muted by the profile")
+	}
+}
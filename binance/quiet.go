@@ -0,0 +1,165 @@
+package filter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"alertbot/command"
+	"alertbot/format"
+)
+
+const (
+	quietStateFile = "quiet.json"
+	digestCount    = 10
+)
+
+// deferredAlert waits for the digest
+type deferredAlert struct {
+	msg      format.Message
+	priority float64
+}
+
+// quietHours defers the alerts below the priority into a digest, From and To are 15:04 in local time
+type quietHours struct {
+	mu       sync.Mutex
+	From     string
+	To       string
+	deferred []deferredAlert
+}
+
+func (q *quietHours) load() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return loadState(quietStateFile, q)
+}
+
+// set the quiet hours, empty From and To turn them off
+func (q *quietHours) set(from string, to string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.From, q.To = from, to
+	return saveState(quietStateFile, q)
+}
+
+// active reports whether now is within the quiet hours, which may span midnight
+func (q *quietHours) active(now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.From == "" {
+		return false
+	}
+
+	clock := now.Format("15:04")
+	if q.From <= q.To {
+		return q.From <= clock && clock < q.To
+	}
+	return clock >= q.From || clock < q.To
+}
+
+func (q *quietHours) add(msg format.Message, priority float64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.deferred = append(q.deferred, deferredAlert{msg: msg, priority: priority})
+}
+
+// flush the deferred alerts
+func (q *quietHours) flush() []deferredAlert {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ret := q.deferred
+	q.deferred = nil
+	return ret
+}
+
+func (q *quietHours) String() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.From == "" {
+		return "no quiet hours"
+	}
+
+	return fmt.Sprintf("quiet from %s to %s, %d alert(s) deferred", q.From, q.To, len(q.deferred))
+}
+
+// digest of the deferred alerts, the count by channel and the highest priorities
+func digest(deferred []deferredAlert) string {
+	counts := map[string]int{}
+	for _, d := range deferred {
+		counts[d.msg.Channel]++
+	}
+	channels := make([]string, 0, len(counts))
+	for channel, count := range counts {
+		channels = append(channels, fmt.Sprintf("%s %d", channel, count))
+	}
+	sort.Strings(channels)
+
+	sort.SliceStable(deferred, func(i, j int) bool { return deferred[i].priority > deferred[j].priority })
+	lines := []string{fmt.Sprintf("<b>Digest</b> of %d alert(s) deferred during the quiet hours: %s", len(deferred), strings.Join(channels, ", "))}
+	for i, d := range deferred {
+		if i == digestCount {
+			break
+		}
+		lines = append(lines, d.msg.Render(format.HTML))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// handleQuietHours post the digest once the quiet hours are over
+func (bf *BinanceFilter) handleQuietHours() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if bf.quiet.active(now.In(bf.localTime)) {
+			continue
+		}
+
+		if deferred := bf.quiet.flush(); len(deferred) > 0 {
+			bf.postMessage(SYSTEM, digest(deferred))
+		}
+	}
+}
+
+// Quiet set the quiet hours in local time, e.g. "23:00 07:00", "off"
+func (bf *BinanceFilter) Quiet(c *command.Context) {
+	if !c.Has("from") {
+		c.Reply(bf.quiet.String())
+		return
+	}
+
+	from, to, target := "", "", "off"
+	if strings.ToLower(c.String("from")) != "off" {
+		start, err := time.Parse("15:04", c.String("from"))
+		if err != nil {
+			c.Invalid("from", "not a time, e.g. 23:00")
+			return
+		}
+
+		end, err := time.Parse("15:04", c.String("to"))
+		if err != nil || start.Equal(end) {
+			c.Invalid("to", "not a time other than from, e.g. 07:00")
+			return
+		}
+
+		from, to = start.Format("15:04"), end.Format("15:04")
+		target = from + "-" + to
+	}
+
+	if err := bf.quiet.set(from, to); err != nil {
+		c.Reply(err.Error())
+		return
+	}
+	bf.recordChange(c.Request.User, "quiet", target, "", "")
+
+	c.Reply(bf.quiet.String())
+}
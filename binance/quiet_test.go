@@ -0,0 +1,73 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"alertbot/format"
+)
+
+func TestQuietHoursActive(t *testing.T) {
+	tests := []struct {
+		from, to string
+		clock    string
+		want     bool
+	}{
+		{"", "", "03:00", false},
+		// within a day
+		{"12:00", "14:00", "11:59", false},
+		{"12:00", "14:00", "12:00", true},
+		{"12:00", "14:00", "13:59", true},
+		{"12:00", "14:00", "14:00", false},
+		// across midnight
+		{"23:00", "07:00", "22:59", false},
+		{"23:00", "07:00", "23:00", true},
+		{"23:00", "07:00", "00:00", true},
+		{"23:00", "07:00", "06:59", true},
+		{"23:00", "07:00", "07:00", false},
+		{"23:00", "07:00", "12:00", false},
+	}
+
+	for _, tt := range tests {
+		q := &quietHours{From: tt.from, To: tt.to}
+		now, _ := time.Parse("15:04", tt.clock)
+		if got := q.active(now); got != tt.want {
+			t.Errorf("quiet %s-%s active at %s = %v, want %v", tt.from, tt.to, tt.clock, got, tt.want)
+		}
+	}
+}
+
+func TestQuietHoursLocalTime(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*3600)
+	q := &quietHours{From: "23:00", To: "07:00"}
+
+	// 15:00 UTC is midnight in Tokyo
+	now := time.Date(2024, 1, 1, 15, 0, 0, 0, time.UTC)
+	if !q.active(now.In(tokyo)) {
+		t.Error("not quiet at midnight local time")
+	}
+	if q.active(now) {
+		t.Error("quiet at 15:00 UTC")
+	}
+}
+
+func TestDigest(t *testing.T) {
+	deferred := []deferredAlert{}
+	for i := 0; i < digestCount+2; i++ {
+		channel := UP
+		if i%3 == 0 {
+			channel = DOWN
+		}
+		deferred = append(deferred, deferredAlert{msg: format.NewMessage(channel, channel+" "+string(rune('a'+i))), priority: float64(i)})
+	}
+
+	lines := strings.Split(digest(deferred), "\n")
+	if !strings.Contains(lines[0], "12 alert(s)") || !strings.Contains(lines[0], "DOWN 4, UP 8") {
+		t.Errorf("digest header %q", lines[0])
+	}
+	// the highest priorities first, up to digestCount
+	if len(lines) != digestCount+1 || lines[1] != "UP l" || lines[digestCount] != "UP c" {
+		t.Errorf("digest lines %q", lines[1:])
+	}
+}
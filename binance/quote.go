@@ -0,0 +1,110 @@
+package filter
+
+import (
+	"strings"
+
+	"go.uber.org/atomic"
+)
+
+const defaultQuoteAssets = "USDT"
+
+// usdQuotes are pegged to the USD, their amounts need no conversion
+var usdQuotes = map[string]struct{}{
+	"USDT": {}, "FDUSD": {}, "USDC": {}, "BUSD": {}, "TUSD": {}, "USDP": {}, "DAI": {},
+}
+
+// pair of a symbol from the exchange info
+type pair struct {
+	base  string
+	quote string
+}
+
+// quoteAssets of QUOTE_ASSETS, e.g. "USDT,FDUSD,USDC,BTC", the first one is the default quote of the commands
+func quoteAssets(s string) []string {
+	ret := []string{}
+	for _, quote := range strings.Split(strings.ToUpper(s), ",") {
+		if quote = strings.TrimSpace(quote); quote != "" && !contains(ret, quote) {
+			ret = append(ret, quote)
+		}
+	}
+	if len(ret) == 0 {
+		return []string{defaultQuoteAssets}
+	}
+
+	return ret
+}
+
+// addPair of the exchange info, reporting whether its quote asset is tracked,
+// a non-USD quote asset is priced by its USDT symbol, e.g. BTCUSDT
+func (bf *BinanceFilter) addPair(symbol string, base string, quote string) bool {
+	if !contains(bf.quotes, quote) ||
+		strings.HasPrefix(base, "USD") || strings.HasSuffix(base, "USD") {
+		return false
+	}
+
+	bf.pairs[symbol] = pair{base: base, quote: quote}
+	if _, found := usdQuotes[quote]; !found {
+		if _, found := bf.usdPrices[quote+"USDT"]; !found {
+			bf.usdPrices[quote+"USDT"] = atomic.NewFloat64(0)
+		}
+	}
+
+	return true
+}
+
+// symbolOf a name given to a command, either a symbol or a base paired with the first quote asset listing it,
+// the default quote asset is appended when not found
+func (bf *BinanceFilter) symbolOf(name string) (string, bool) {
+	name = strings.ToUpper(name)
+	if _, found := bf.market[name]; found {
+		return name, true
+	}
+
+	for _, quote := range bf.quotes {
+		if _, found := bf.market[name+quote]; found {
+			return name + quote, true
+		}
+	}
+
+	return name + bf.quotes[0], false
+}
+
+// baseOf a symbol
+func (bf *BinanceFilter) baseOf(symbol string) string {
+	if p, found := bf.pairs[symbol]; found {
+		return p.base
+	}
+	return symbol
+}
+
+// quoteOf a symbol, empty if unknown
+func (bf *BinanceFilter) quoteOf(symbol string) string {
+	return bf.pairs[symbol].quote
+}
+
+// short name of a symbol, its base for the default quote asset and the symbol otherwise,
+// a COIN-M contract is named after its spot market
+func (bf *BinanceFilter) short(symbol string) string {
+	symbol = bf.spotOf(symbol)
+	if p, found := bf.pairs[symbol]; found && p.quote == bf.quotes[0] {
+		return p.base
+	}
+	return symbol
+}
+
+// usd value of an amount in the quote asset of a symbol, zero until its quote asset is priced
+func (bf *BinanceFilter) usd(symbol string, amount float64) float64 {
+	p, found := bf.pairs[symbol]
+	if !found {
+		return 0
+	}
+
+	if _, found := usdQuotes[p.quote]; found {
+		return amount
+	}
+	if price, found := bf.usdPrices[p.quote+"USDT"]; found {
+		return amount * price.Load()
+	}
+
+	return 0
+}
@@ -0,0 +1,276 @@
+package filter
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"alertbot/record"
+)
+
+const reportStateFile = "reports.json"
+
+type schedule struct {
+	ID     int
+	Every  time.Duration `json:",omitempty"`
+	At     string        `json:",omitempty"` // 15:04 in local time
+	Window time.Duration
+	Last   int64 `json:",omitempty"` // milliseconds of the last report, the start of an every schedule
+}
+
+// due reports whether the schedule fires at now, an every schedule once its period elapsed since the last report
+func (s schedule) due(now time.Time) bool {
+	if s.At != "" {
+		return now.Format("15:04") == s.At
+	}
+
+	return now.Truncate(time.Minute).Sub(time.UnixMilli(s.Last)) >= s.Every
+}
+
+func (s schedule) String() string {
+	if s.At != "" {
+		return fmt.Sprintf("#%d at %s window %s", s.ID, s.At, s.Window)
+	}
+
+	return fmt.Sprintf("#%d every %s window %s", s.ID, s.Every, s.Window)
+}
+
+// reports keeps the persisted report schedules
+type reports struct {
+	mu        sync.Mutex
+	Schedules []schedule
+	NextID    int
+}
+
+func (r *reports) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return loadState(reportStateFile, r)
+}
+
+// add a schedule starting at now
+func (r *reports) add(s schedule, now time.Time) (schedule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.NextID++
+	s.ID = r.NextID
+	s.Last = now.Truncate(time.Minute).UnixMilli()
+	r.Schedules = append(r.Schedules, s)
+
+	return s, saveState(reportStateFile, r)
+}
+
+func (r *reports) remove(id int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, s := range r.Schedules {
+		if s.ID == id {
+			r.Schedules = append(r.Schedules[:i], r.Schedules[i+1:]...)
+			return true, saveState(reportStateFile, r)
+		}
+	}
+
+	return false, nil
+}
+
+// due schedules at now, recording their report time
+func (r *reports) due(now time.Time) []schedule {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ret := []schedule{}
+	for i := range r.Schedules {
+		s := &r.Schedules[i]
+		if s.Every > 0 && s.Last == 0 {
+			// schedules saved without start begin now
+			s.Last = now.Truncate(time.Minute).UnixMilli()
+			continue
+		}
+
+		if s.due(now) {
+			s.Last = now.Truncate(time.Minute).UnixMilli()
+			ret = append(ret, *s)
+		}
+	}
+
+	if len(ret) > 0 {
+		if err := saveState(reportStateFile, r); err != nil {
+			log.Println(err)
+		}
+	}
+
+	return ret
+}
+
+func (r *reports) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.Schedules) == 0 {
+		return "no report"
+	}
+
+	lines := []string{}
+	for _, s := range r.Schedules {
+		lines = append(lines, s.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (bf *BinanceFilter) handleReports() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		for _, s := range bf.reports.due(now.In(bf.localTime)) {
+			bf.postMessage(SYSTEM, bf.report(s.Window))
+		}
+	}
+}
+
+// report summarize the market and the alerts of the last window
+func (bf *BinanceFilter) report(window time.Duration) string {
+	now := time.Now()
+	from := now.Add(-window).UnixMilli()
+
+	type change struct {
+		symbol string
+		rate   float64
+	}
+	changes := []change{}
+	covered := now.UnixMilli()
+	for symbol := range bf.market {
+		first, last, since, ok := bf.priceSince(symbol, from)
+		if !ok {
+			continue
+		}
+		if since < covered {
+			covered = since
+		}
+		changes = append(changes, change{symbol: symbol, rate: (last - first) * 100 / first})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].rate > changes[j].rate })
+
+	ret := fmt.Sprintf("<b>#REPORT %s</b> %s\n", window, now.In(bf.localTime).Format("15:04:05 2006-01-02"))
+	if covered > from {
+		ret += fmt.Sprintf("Prices over last %s only\n", time.Duration(now.UnixMilli()-covered)*time.Millisecond/time.Minute*time.Minute)
+	}
+
+	top := 5
+	if len(changes) < top {
+		top = len(changes)
+	}
+	ret += "\n<b>Gainers</b>\n"
+	for _, c := range changes[:top] {
+		ret += fmt.Sprintf("%s: %+0.2f%%\n", bf.short(c.symbol), c.rate)
+	}
+	ret += "\n<b>Losers</b>\n"
+	for i := 1; i <= top; i++ {
+		c := changes[len(changes)-i]
+		ret += fmt.Sprintf("%s: %+0.2f%%\n", bf.short(c.symbol), c.rate)
+	}
+
+	symbols := bf.fundingRanking()
+	if len(symbols) > 3 {
+		ret += "\n<b>Funding</b>\n"
+		for _, symbol := range symbols[:3] {
+			ret += fmt.Sprintf("%s: %0.4f\n", symbol, bf.funding[symbol].Load())
+		}
+		for i := 3; i >= 1; i-- {
+			symbol := symbols[len(symbols)-i]
+			ret += fmt.Sprintf("%s: %0.4f\n", symbol, bf.funding[symbol].Load())
+		}
+	}
+
+	channels, alerted := make(map[string]int), make(map[string]int)
+	if bf.store != nil {
+		if err := bf.store.Each(record.Query{From: from}, func(a *record.Alert) error {
+			channels[a.Channel]++
+			alerted[a.Symbol]++
+			return nil
+		}); err != nil {
+			log.Println(err)
+		}
+	}
+	alertedSymbols := make([]string, 0, len(alerted))
+	for symbol := range alerted {
+		alertedSymbols = append(alertedSymbols, symbol)
+	}
+	sort.Slice(alertedSymbols, func(i, j int) bool {
+		if alerted[alertedSymbols[i]] == alerted[alertedSymbols[j]] {
+			return alertedSymbols[i] < alertedSymbols[j]
+		}
+		return alerted[alertedSymbols[i]] > alerted[alertedSymbols[j]]
+	})
+	if len(alertedSymbols) > 5 {
+		alertedSymbols = alertedSymbols[:5]
+	}
+	ret += "\n<b>Most alerted</b>\n"
+	for _, symbol := range alertedSymbols {
+		ret += fmt.Sprintf("%s: %d\n", bf.short(symbol), alerted[symbol])
+	}
+
+	ret += "\n<b>Alerts</b>\n"
+	for _, channel := range []string{UP, DOWN, BUY, SELL, FBUY, FSELL} {
+		ret += fmt.Sprintf("%s: %d\n", channel, channels[channel])
+	}
+
+	return ret
+}
+
+// priceSince find the oldest price since from and the latest one
+func (bf *BinanceFilter) priceSince(symbol string, from int64) (float64, float64, int64, bool) {
+	first, _, _, last, since, ok := bf.market[symbol].span(from)
+	return first, last, since, ok
+}
+
+func parseSchedule(s []string) (schedule, error) {
+	if len(s) < 2 || len(s) > 3 {
+		return schedule{}, fmt.Errorf("wrong format")
+	}
+
+	ret := schedule{}
+	switch strings.ToLower(s[0]) {
+	case "every":
+		every, err := time.ParseDuration(s[1])
+		if err != nil || every < time.Minute || every%time.Minute != 0 || every > 24*time.Hour {
+			return schedule{}, fmt.Errorf("wrong format")
+		}
+		ret.Every, ret.Window = every, every
+	case "at":
+		at, err := time.Parse("15:04", s[1])
+		if err != nil {
+			return schedule{}, fmt.Errorf("wrong format")
+		}
+		ret.At, ret.Window = at.Format("15:04"), 24*time.Hour
+	default:
+		return schedule{}, fmt.Errorf("wrong format")
+	}
+
+	if len(s) == 3 {
+		window, err := time.ParseDuration(s[2])
+		if err != nil || window <= 0 || window > 24*time.Hour {
+			return schedule{}, fmt.Errorf("wrong format")
+		}
+		ret.Window = window
+	}
+
+	return ret, nil
+}
+
+func parseID(s string) (int, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(s, "#"))
+	if err != nil {
+		return 0, fmt.Errorf("wrong format")
+	}
+
+	return id, nil
+}
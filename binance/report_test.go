@@ -0,0 +1,45 @@
+package filter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleEvery(t *testing.T) {
+	t.Setenv("DATA_LOCATION", t.TempDir())
+
+	start := time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC)
+	r := &reports{}
+	if _, err := r.add(schedule{Every: 7 * time.Hour, Window: 7 * time.Hour}, start); err != nil {
+		t.Fatal(err)
+	}
+
+	// every 7h fires at even intervals across midnight, not at minute-of-day multiples
+	fired := []string{}
+	for now := start; now.Before(start.Add(48 * time.Hour)); now = now.Add(time.Minute) {
+		if len(r.due(now)) > 0 {
+			fired = append(fired, now.Format("02 15:04"))
+		}
+	}
+
+	want := []string{"01 21:00", "02 04:00", "02 11:00", "02 18:00", "03 01:00", "03 08:00"}
+	if len(fired) != len(want) {
+		t.Fatalf("fired at %v, want %v", fired, want)
+	}
+	for i := range want {
+		if fired[i] != want[i] {
+			t.Errorf("fired at %v, want %v", fired, want)
+			break
+		}
+	}
+}
+
+func TestScheduleAt(t *testing.T) {
+	s := schedule{At: "08:30"}
+	if !s.due(time.Date(2024, 1, 1, 8, 30, 20, 0, time.UTC)) {
+		t.Error("not due at 08:30")
+	}
+	if s.due(time.Date(2024, 1, 1, 8, 31, 0, 0, time.UTC)) {
+		t.Error("due at 08:31")
+	}
+}
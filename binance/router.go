@@ -0,0 +1,342 @@
+package filter
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"alertbot/command"
+	"alertbot/format"
+)
+
+var (
+	errNotFound      = errors.New("not found")
+	errWrongAccessID = errors.New("not <messenger>:<id>, e.g. telegram:123456")
+)
+
+func (bf *BinanceFilter) checkSymbol(name string) error {
+	if _, found := bf.symbolOf(name); !found {
+		return errNotFound
+	}
+	return nil
+}
+
+func (bf *BinanceFilter) checkChannel(name string) error {
+	if _, found := bf.channel[strings.ToUpper(name)]; !found {
+		return errNotFound
+	}
+	return nil
+}
+
+func checkAlertChannel(name string) error {
+	if _, found := defaultTemplates[strings.ToUpper(name)]; !found {
+		return errNotFound
+	}
+	return nil
+}
+
+// Router of the commands, replies are sent to the chat of the command
+func (bf *BinanceFilter) Router() *command.Router {
+	return bf.router
+}
+
+// DefaultChats name the chats the empty destination reaches, e.g. "telegram:123",
+// so that a default chat subscribing gets each alert once
+func (bf *BinanceFilter) DefaultChats(chats ...string) {
+	bf.defaultChats = chats
+}
+
+func (bf *BinanceFilter) newRouter() *command.Router {
+	r := command.NewRouter(func(to string, s string) {
+		bf.postMessageBackend(to, format.NewMessage(SYSTEM, s))
+	}, func(to string, caption string, photo []byte) {
+		bf.postPhotoBackend(to, format.NewMessage(SYSTEM, caption), photo)
+	})
+	r.Access = bf.access
+
+	symbol := command.Arg{Name: "symbol", Check: bf.checkSymbol, Fallback: bf.replySymbol}
+	channel := command.Arg{Name: "channel", Check: bf.checkChannel}
+	optionalChannel := command.Arg{Name: "channel", Check: bf.checkChannel, Optional: true}
+	target := command.Arg{Name: "target", Fallback: bf.replySymbol}
+	sinceArg := command.Arg{Name: "since", Optional: true}
+
+	watchKeys := make([]string, 0, len(watchlistThresholds))
+	for key := range watchlistThresholds {
+		watchKeys = append(watchKeys, key)
+	}
+	sort.Strings(watchKeys)
+
+	r.Add(&command.Command{Names: []string{"/update"}, Summary: "Reload the symbols", Role: command.Admin, Run: bf.UpdateData})
+	r.Add(&command.Command{
+		Names:    []string{"/set", "/s"},
+		Summary:  "Set a threshold, volumes in $, rates in %, window in minutes, retention in hours",
+		Args:     []command.Arg{{Name: "key", Kind: command.Choice, Choices: configurable}, {Name: "value", Kind: command.Float}},
+		Examples: []string{"/set up 2.5", "/set minvolume 20000000"},
+		Role:     command.Admin,
+		Run:      bf.UpdateConfiguration,
+	})
+	r.Add(&command.Command{
+		Names:   []string{"/get", "/g"},
+		Summary: "Show the thresholds",
+		Args:    []command.Arg{{Name: "config", Kind: command.Choice, Choices: []string{"config"}, Optional: true}},
+		Run:     bf.GetConfiguration,
+	})
+	r.Add(&command.Command{
+		Names:    []string{"/mute"},
+		Summary:  "Mute a channel, for a while with a duration",
+		Args:     []command.Arg{channel, {Name: "duration", Kind: command.Duration, Optional: true}},
+		Examples: []string{"/mute BUY 30m"},
+		Role:     command.Operator,
+		Run:      bf.Mute,
+	})
+	r.Add(&command.Command{Names: []string{"/unmute"}, Summary: "Unmute a channel, ALL for every channel", Role: command.Operator, Args: []command.Arg{channel}, Run: bf.Unmute})
+	r.Add(&command.Command{Names: []string{"/restart"}, Summary: "Restart the streams", Role: command.Admin, Run: bf.Restart})
+	r.Add(&command.Command{
+		Names:    []string{"/cooldown"},
+		Summary:  "Set the minimum interval between alerts of a channel or a symbol",
+		Args:     []command.Arg{{Name: "target", Optional: true}, {Name: "duration", Kind: command.Duration, Optional: true}},
+		Examples: []string{"/cooldown UP 5m", "/cooldown SOL 0"},
+		Role:     command.Operator,
+		Run:      bf.Cooldown,
+	})
+	r.Add(&command.Command{
+		Names:    []string{"/budget"},
+		Summary:  "Set the maximum alerts per hour of a channel, 0 for unlimited",
+		Args:     []command.Arg{optionalChannel, {Name: "count", Kind: command.Int, Optional: true}},
+		Examples: []string{"/budget BUY 20"},
+		Role:     command.Operator,
+		Run:      bf.Budget,
+	})
+	r.Add(&command.Command{
+		Names:   []string{"/watch", "/w"},
+		Summary: "Manage the watchlists",
+		Subcommands: []*command.Command{
+			{Names: []string{"list"}, Summary: "List the watchlists", Run: bf.Watchlists},
+			{
+				Names:   []string{"add"},
+				Summary: "Add symbols to a watchlist",
+				Args:    []command.Arg{{Name: "name"}, {Name: "symbol", Many: true, Check: bf.checkSymbol}},
+				Role:    command.Operator,
+				Run:     bf.WatchAdd,
+			},
+			{
+				Names:   []string{"remove"},
+				Summary: "Remove symbols from a watchlist, the watchlist without symbol",
+				Args:    []command.Arg{{Name: "name"}, {Name: "symbol", Many: true, Optional: true}},
+				Role:    command.Operator,
+				Run:     bf.WatchRemove,
+			},
+			{
+				Names:   []string{"set"},
+				Summary: "Override a threshold for the symbols of a watchlist",
+				Args:    []command.Arg{{Name: "name"}, {Name: "key", Kind: command.Choice, Choices: watchKeys}, {Name: "value", Kind: command.Float}},
+				Role:    command.Admin,
+				Run:     bf.WatchSet,
+			},
+			{
+				Names:   []string{"route"},
+				Summary: "Send the alerts of a watchlist to a chat, the default one without destination",
+				Args:    []command.Arg{{Name: "name"}, {Name: "destination", Optional: true}},
+				Role:    command.Operator,
+				Run:     bf.WatchRoute,
+			},
+		},
+		Examples: []string{"/watch add defi SOL AVAX", "/watch set defi up 1", "/watch route defi -100123"},
+		Run:      bf.Watchlists,
+	})
+	r.Add(&command.Command{
+		Names:    []string{"/filter"},
+		Summary:  "Restrict a channel to a watchlist",
+		Args:     []command.Arg{optionalChannel, {Name: "watchlist", Optional: true}},
+		Examples: []string{"/filter FSELL defi"},
+		Role:     command.Operator,
+		Run:      bf.Filter,
+	})
+	r.Add(&command.Command{Names: []string{"/clear"}, Summary: "Clear the restriction of a channel, of all without channel", Args: []command.Arg{optionalChannel}, Role: command.Operator, Run: bf.Clear})
+	r.Add(&command.Command{
+		Names:    []string{"/ignore"},
+		Summary:  "Ignore a symbol, for a while with a duration",
+		Args:     []command.Arg{symbol, {Name: "duration", Kind: command.Duration, Optional: true}},
+		Examples: []string{"/ignore EOS 2h"},
+		Role:     command.Operator,
+		Run:      bf.Ignore,
+	})
+	r.Add(&command.Command{
+		Names:   []string{"/rule"},
+		Summary: "Manage the ignore rules matching the symbol bases",
+		Subcommands: []*command.Command{
+			{Names: []string{"list"}, Summary: "List the ignore rules", Run: bf.Rules},
+			{
+				Names: []string{"add"},
+				Summary: "Ignore the bases matching a glob, a /regexp/ or a category among " + strings.Join(categoryNames(), ", ") +
+					", in some alert channels, spot or futures only",
+				Args: []command.Arg{{Name: "pattern"}, {Name: "scope", Many: true, Optional: true}},
+				Role: command.Operator,
+				Run:  bf.RuleAdd,
+			},
+			{Names: []string{"remove"}, Summary: "Remove an ignore rule", Args: []command.Arg{{Name: "id"}}, Role: command.Operator, Run: bf.RuleRemove},
+		},
+		Examples: []string{"/rule add *UP", "/rule add 1000* FBUY FSELL", "/rule add /^(BTC|ETH)$/ futures", "/rule add @stablecoins", "/rule remove 2"},
+		Run:      bf.Rules,
+	})
+	r.Add(&command.Command{Names: []string{"/ignored"}, Summary: "List the ignored symbols, the snoozed ones and the muted channels with their remaining time", Run: bf.Ignored})
+	r.Add(&command.Command{Names: []string{"/unignore"}, Summary: "Stop ignoring a symbol", Args: []command.Arg{{Name: "symbol"}}, Role: command.Operator, Run: bf.Unignore})
+	r.Add(&command.Command{
+		Names:    []string{"/snooze"},
+		Summary:  "Silence a symbol for a while, 1h by default, 0 to stop",
+		Args:     []command.Arg{{Name: "symbol", Check: bf.checkSymbol, Optional: true}, {Name: "duration", Kind: command.Duration, Optional: true}},
+		Examples: []string{"/snooze EOS 30m"},
+		Role:     command.Operator,
+		Run:      bf.Snooze,
+	})
+	r.Add(&command.Command{Names: []string{"/price", "/p"}, Summary: "Show the price of a symbol", Args: []command.Arg{symbol}, Run: bf.Price})
+	r.Add(&command.Command{
+		Names:    []string{"/chart", "/c"},
+		Summary:  "Chart a symbol over a window, the alert window by default",
+		Args:     []command.Arg{symbol, {Name: "window", Kind: command.Duration, Optional: true}},
+		Examples: []string{"/chart SOL 30m"},
+		Run:      bf.Chart,
+	})
+	r.Add(&command.Command{
+		Names:   []string{"/report", "/r"},
+		Summary: "Manage the scheduled reports",
+		Subcommands: []*command.Command{
+			{Names: []string{"list"}, Summary: "List the schedules", Run: bf.Reports},
+			{Names: []string{"now"}, Summary: "Report the last window, 1h by default", Args: []command.Arg{{Name: "window", Kind: command.Duration, Optional: true}}, Run: bf.ReportNow},
+			{Names: []string{"every"}, Summary: "Report at an interval", Args: []command.Arg{{Name: "when"}, {Name: "window", Optional: true}}, Role: command.Operator, Run: bf.ReportSchedule("every")},
+			{Names: []string{"at"}, Summary: "Report daily at a local time", Args: []command.Arg{{Name: "when"}, {Name: "window", Optional: true}}, Role: command.Operator, Run: bf.ReportSchedule("at")},
+			{Names: []string{"remove"}, Summary: "Remove a schedule", Args: []command.Arg{{Name: "id"}}, Role: command.Operator, Run: bf.ReportRemove},
+		},
+		Examples: []string{"/report every 4h", "/report at 08:00 12h", "/report now 1h"},
+		Run:      bf.Reports,
+	})
+	r.Add(&command.Command{
+		Names:    []string{"/history", "/h"},
+		Summary:  "List the stored alerts of a channel or a symbol since a time, 24h by default",
+		Args:     []command.Arg{target, sinceArg, {Name: "top", Kind: command.Choice, Choices: []string{"top"}, Optional: true}, {Name: "count", Kind: command.Int, Optional: true}},
+		Examples: []string{"/history SOL 24h", "/history FSELL 1h top 10", "/history UP today"},
+		Run:      bf.History,
+	})
+	r.Add(&command.Command{
+		Names:    []string{"/count"},
+		Summary:  "Count the stored alerts of a channel or a symbol since a time",
+		Args:     []command.Arg{target, sinceArg},
+		Examples: []string{"/count UP today"},
+		Run:      bf.Count,
+	})
+	r.Add(&command.Command{
+		Names:    []string{"/perf"},
+		Summary:  "Show the forward returns of the stored alerts since a time",
+		Args:     []command.Arg{target, sinceArg},
+		Examples: []string{"/perf UP 7d"},
+		Run:      bf.Performance,
+	})
+	r.Add(&command.Command{
+		Names:   []string{"/webhook"},
+		Summary: "Manage the webhook routes",
+		Subcommands: []*command.Command{
+			{Names: []string{"list"}, Summary: "List the routes", Run: bf.Webhooks},
+			{
+				Names:   []string{"add"},
+				Summary: "Forward a channel, ALL or SYSTEM to a URL, signed with the secret",
+				Args: []command.Arg{{Name: "channel", Check: func(s string) error {
+					if strings.ToUpper(s) == SYSTEM {
+						return nil
+					}
+					return bf.checkChannel(s)
+				}}, {Name: "url"}, {Name: "secret", Optional: true}},
+				Role: command.Admin,
+				Run:  bf.WebhookAdd,
+			},
+			{Names: []string{"remove"}, Summary: "Remove a route", Args: []command.Arg{{Name: "id"}}, Role: command.Admin, Run: bf.WebhookRemove},
+		},
+		Examples: []string{"/webhook add FSELL https://example.com/hook secret", "/webhook remove 1"},
+		Run:      bf.Webhooks,
+	})
+	r.Add(&command.Command{
+		Names:    []string{"/template"},
+		Summary:  "Set the alert template of a channel, reset for the default one",
+		Args:     []command.Arg{{Name: "channel", Check: checkAlertChannel, Optional: true}, {Name: "template", Rest: true, Optional: true}},
+		Examples: []string{"/template UP <b>#{{.Channel}} {{base .Symbol}}</b> {{rate .Rate}}", "/template UP reset"},
+		Role:     command.Admin,
+		Run:      bf.Template,
+	})
+	r.Add(&command.Command{
+		Names:   []string{"/subscribe", "/sub"},
+		Summary: "Manage your alerts in this chat besides the default one",
+		Subcommands: []*command.Command{
+			{Names: []string{"show"}, Summary: "Show your subscription", Run: bf.Subscription},
+			{Names: []string{"add"}, Summary: "Subscribe to alert channels and symbols, all of them by default", Args: []command.Arg{{Name: "target", Many: true, Optional: true}}, Run: bf.Subscribe},
+			{Names: []string{"remove"}, Summary: "Unsubscribe from alert channels and symbols, from everything without them", Args: []command.Arg{{Name: "target", Many: true, Optional: true}}, Run: bf.Unsubscribe},
+			{
+				Names:   []string{"set"},
+				Summary: "Override a threshold of your subscription, volumes in $, rates in %",
+				Args:    []command.Arg{{Name: "key", Kind: command.Choice, Choices: subscriptionThresholds}, {Name: "value", Kind: command.Float}},
+				Role:    command.Operator,
+				Run:     bf.SubscriptionSet,
+			},
+			{
+				Names:   []string{"unset"},
+				Summary: "Remove a threshold override",
+				Args:    []command.Arg{{Name: "key", Kind: command.Choice, Choices: subscriptionThresholds}},
+				Role:    command.Operator,
+				Run:     bf.SubscriptionUnset,
+			},
+			{Names: []string{"list"}, Summary: "List the subscriptions of every user", Role: command.Admin, Run: bf.Subscriptions},
+		},
+		Examples: []string{"/subscribe add UP FSELL", "/subscribe add SOL AVAX", "/subscribe set up 1.5", "/subscribe remove"},
+		Run:      bf.Subscription,
+	})
+	r.Add(&command.Command{
+		Names:   []string{"/profile"},
+		Summary: "Manage the profiles of thresholds and muted channels",
+		Subcommands: []*command.Command{
+			{Names: []string{"list"}, Summary: "List the profiles and their schedules", Run: bf.Profiles},
+			{Names: []string{"save"}, Summary: "Save the thresholds and the muted channels", Args: []command.Arg{{Name: "name"}}, Role: command.Admin, Run: bf.ProfileSave},
+			{Names: []string{"load"}, Summary: "Apply a profile", Args: []command.Arg{{Name: "name"}}, Role: command.Admin, Run: bf.ProfileLoad},
+			{Names: []string{"remove"}, Summary: "Remove a profile and its schedules", Args: []command.Arg{{Name: "name"}}, Role: command.Admin, Run: bf.ProfileRemove},
+			{Names: []string{"at"}, Summary: "Load a profile daily at a local time, off to unschedule", Args: []command.Arg{{Name: "time"}, {Name: "name"}}, Role: command.Admin, Run: bf.ProfileSchedule},
+		},
+		Examples: []string{"/profile save quiet", "/profile at 22:00 quiet", "/profile at 20:30 aggressive", "/profile at 22:00 off"},
+		Run:      bf.Profiles,
+	})
+	r.Add(&command.Command{
+		Names:    []string{"/quiet"},
+		Summary:  "Set the quiet hours in local time, alerts below the priority are deferred into a digest",
+		Args:     []command.Arg{{Name: "from", Optional: true}, {Name: "to", Optional: true}},
+		Examples: []string{"/quiet 23:00 07:00", "/quiet off"},
+		Role:     command.Operator,
+		Run:      bf.Quiet,
+	})
+	r.Add(&command.Command{
+		Names:    []string{"/audit"},
+		Summary:  "List the last changes of the configuration with their user, 10 by default",
+		Args:     []command.Arg{{Name: "count", Kind: command.Int, Optional: true}},
+		Examples: []string{"/audit 20"},
+		Role:     command.Operator,
+		Run:      bf.Audit,
+	})
+	r.Add(&command.Command{Names: []string{"/undo"}, Summary: "Revert the last change of the configuration", Role: command.Admin, Run: bf.Undo})
+	r.Add(&command.Command{
+		Names:   []string{"/access"},
+		Summary: "Manage the roles of users and chats, viewer, operator or admin",
+		Subcommands: []*command.Command{
+			{Names: []string{"list"}, Summary: "List the allowed users and chats", Run: bf.Access},
+			{
+				Names:   []string{"grant"},
+				Summary: "Give a role to a user or a chat, everyone in a chat has its role",
+				Args:    []command.Arg{{Name: "id", Check: checkAccessID}, {Name: "role", Kind: command.Choice, Choices: []string{"viewer", "operator", "admin"}}},
+				Run:     bf.AccessGrant,
+			},
+			{Names: []string{"revoke"}, Summary: "Revoke the granted role of a user or a chat", Args: []command.Arg{{Name: "id"}}, Run: bf.AccessRevoke},
+		},
+		Examples: []string{"/access grant telegram:123456 operator", "/access grant telegram:-100123 viewer", "/access revoke discord:987654"},
+		Role:     command.Admin,
+		Run:      bf.Access,
+	})
+	r.Add(&command.Command{Names: []string{"/fr", "/f"}, Summary: "Show the funding rate of a symbol", Args: []command.Arg{{Name: "symbol", Fallback: bf.replySymbol}}, Run: bf.FundingRate})
+	r.Add(&command.Command{Names: []string{"/frtop", "/ft"}, Summary: "List the highest funding rates", Args: []command.Arg{{Name: "count", Kind: command.Int, Optional: true}}, Run: bf.FundingRateTop})
+	r.Add(&command.Command{Names: []string{"/frbot", "/fb"}, Summary: "List the lowest funding rates", Args: []command.Arg{{Name: "count", Kind: command.Int, Optional: true}}, Run: bf.FundingRateBottom})
+
+	return r
+}
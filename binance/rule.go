@@ -0,0 +1,272 @@
+package filter
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"alertbot/command"
+)
+
+const ruleStateFile = "rules.json"
+
+// markets of the rule scopes
+const (
+	spotMarket    = "spot"
+	futuresMarket = "futures"
+)
+
+// stablecoins of the stablecoins category
+var stablecoins = map[string]struct{}{
+	"USDC": {}, "BUSD": {}, "TUSD": {}, "FDUSD": {}, "DAI": {}, "USDP": {}, "PAX": {}, "PYUSD": {}, "USDD": {}, "UST": {}, "USTC": {}, "AEUR": {}, "EUR": {}, "GBP": {},
+}
+
+var leveragedSuffix = regexp.MustCompile(`^(.+)(UP|DOWN|BULL|BEAR)$`)
+
+// categories of bases excluded by a rule "@name"
+var categories = map[string]func(bf *BinanceFilter, base string) bool{
+	// leveraged tokens, e.g. BTCUP, when their underlying base is listed too
+	"leveraged": func(bf *BinanceFilter, base string) bool {
+		match := leveragedSuffix.FindStringSubmatch(base)
+		if match == nil {
+			return false
+		}
+		_, found := bf.symbolOf(match[1])
+		return found
+	},
+	"stablecoins": func(bf *BinanceFilter, base string) bool {
+		_, found := stablecoins[base]
+		return found
+	},
+}
+
+// rule excludes the bases matching a glob, e.g. "*UP", a regexp between slashes, e.g. "/^1000/",
+// or a category, e.g. "@leveraged", from the channels and markets of its scopes, all of them without scope
+type rule struct {
+	ID      int
+	Pattern string
+	Scopes  []string `json:",omitempty"` // channels, spot or futures
+
+	regexp *regexp.Regexp
+}
+
+// compile the pattern of the rule
+func (r *rule) compile() error {
+	switch {
+	case strings.HasPrefix(r.Pattern, "@"):
+		if _, found := categories[strings.TrimPrefix(r.Pattern, "@")]; !found {
+			return fmt.Errorf("unknown category, one of %s", strings.Join(categoryNames(), ", "))
+		}
+	case len(r.Pattern) > 2 && strings.HasPrefix(r.Pattern, "/") && strings.HasSuffix(r.Pattern, "/"):
+		re, err := regexp.Compile(strings.Trim(r.Pattern, "/"))
+		if err != nil {
+			return err
+		}
+		r.regexp = re
+	default:
+		if _, err := path.Match(r.Pattern, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// matches reports whether the rule excludes a base of a channel
+func (r *rule) matches(bf *BinanceFilter, channel string, base string) bool {
+	if len(r.Scopes) > 0 && !contains(r.Scopes, channel) && !contains(r.Scopes, marketOf(channel)) {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(r.Pattern, "@"):
+		return categories[strings.TrimPrefix(r.Pattern, "@")](bf, base)
+	case r.regexp != nil:
+		return r.regexp.MatchString(base)
+	default:
+		matched, _ := path.Match(r.Pattern, base)
+		return matched
+	}
+}
+
+func (r *rule) String() string {
+	if len(r.Scopes) == 0 {
+		return fmt.Sprintf("#%d %s", r.ID, r.Pattern)
+	}
+	return fmt.Sprintf("#%d %s in %s", r.ID, r.Pattern, strings.Join(r.Scopes, " "))
+}
+
+// marketOf the stream of a channel
+func marketOf(channel string) string {
+	if channel == FBUY || channel == FSELL {
+		return futuresMarket
+	}
+	return spotMarket
+}
+
+func categoryNames() []string {
+	ret := make([]string, 0, len(categories))
+	for name := range categories {
+		ret = append(ret, "@"+name)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
+// rules keeps the persisted ignore rules
+type rules struct {
+	mu     sync.RWMutex
+	Rules  []*rule
+	NextID int
+}
+
+// newRules with the default ones, excluding BTC and ETH from the futures trades
+func newRules() *rules {
+	return &rules{Rules: []*rule{
+		{ID: 1, Pattern: "BTC*", Scopes: []string{FBUY, FSELL}},
+		{ID: 2, Pattern: "ETH*", Scopes: []string{FBUY, FSELL}},
+	}, NextID: 2}
+}
+
+func (r *rules) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the default rules are kept without state
+	loaded := rules{}
+	if err := loadState(ruleStateFile, &loaded); err != nil {
+		return err
+	}
+	if loaded.NextID > 0 {
+		r.Rules, r.NextID = loaded.Rules, loaded.NextID
+	}
+
+	for _, rule := range r.Rules {
+		if err := rule.compile(); err != nil {
+			return fmt.Errorf("rule %s: %w", rule, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *rules) add(rule *rule) (*rule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.NextID++
+	rule.ID = r.NextID
+	r.Rules = append(r.Rules, rule)
+
+	return rule, saveState(ruleStateFile, r)
+}
+
+func (r *rules) remove(id int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, rule := range r.Rules {
+		if rule.ID == id {
+			r.Rules = append(r.Rules[:i], r.Rules[i+1:]...)
+			return true, saveState(ruleStateFile, r)
+		}
+	}
+
+	return false, nil
+}
+
+func (r *rules) String() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.Rules) == 0 {
+		return "no rule"
+	}
+
+	lines := []string{}
+	for _, rule := range r.Rules {
+		lines = append(lines, rule.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// excluded reports whether an ignore rule matches the symbol of a channel
+func (bf *BinanceFilter) excluded(channel string, symbol string) bool {
+	bf.rules.mu.RLock()
+	defer bf.rules.mu.RUnlock()
+
+	base := bf.baseOf(symbol)
+	for _, rule := range bf.rules.Rules {
+		if rule.matches(bf, channel, base) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Rules list the ignore rules
+func (bf *BinanceFilter) Rules(c *command.Context) {
+	c.Reply(bf.rules.String())
+}
+
+// RuleAdd add an ignore rule, e.g. "*UP", "1000* FBUY FSELL", "@stablecoins spot"
+func (bf *BinanceFilter) RuleAdd(c *command.Context) {
+	r := &rule{Pattern: c.String("pattern")}
+	switch {
+	case strings.HasPrefix(r.Pattern, "@"):
+		r.Pattern = strings.ToLower(r.Pattern)
+	case !strings.HasPrefix(r.Pattern, "/"):
+		r.Pattern = strings.ToUpper(r.Pattern)
+	}
+
+	for _, scope := range c.Strings("scope") {
+		switch s := strings.ToLower(scope); {
+		case s == spotMarket || s == futuresMarket:
+			r.Scopes = append(r.Scopes, s)
+		case checkAlertChannel(scope) == nil:
+			r.Scopes = append(r.Scopes, strings.ToUpper(scope))
+		default:
+			c.Invalid("scope", fmt.Sprintf("%s is neither an alert channel, spot nor futures", scope))
+			return
+		}
+	}
+
+	if err := r.compile(); err != nil {
+		c.Invalid("pattern", err.Error())
+		return
+	}
+
+	r, err := bf.rules.add(r)
+	if err != nil {
+		c.Reply(err.Error())
+		return
+	}
+	bf.recordChange(c.Request.User, "rule", "add "+r.String(), "", "")
+
+	c.Reply(fmt.Sprintf("rule %s added", r))
+}
+
+// RuleRemove remove an ignore rule
+func (bf *BinanceFilter) RuleRemove(c *command.Context) {
+	id, err := parseID(c.String("id"))
+	if err != nil {
+		c.Invalid("id", err.Error())
+		return
+	}
+
+	found, err := bf.rules.remove(id)
+	if err != nil {
+		c.Reply(err.Error())
+	} else if !found {
+		c.Reply("not found")
+	} else {
+		bf.recordChange(c.Request.User, "rule", fmt.Sprintf("remove #%d", id), "", "")
+		c.Reply("removed")
+	}
+}
@@ -0,0 +1,75 @@
+package filter
+
+import (
+	"testing"
+)
+
+func TestRuleCompile(t *testing.T) {
+	tests := []struct {
+		pattern string
+		err     bool
+	}{
+		{"*UP", false},
+		{"/^1000/", false},
+		{"@leveraged", false},
+		{"@memes", true},
+		{"/[/", true},
+		{"[", true},
+	}
+
+	for _, tt := range tests {
+		r := &rule{Pattern: tt.pattern}
+		if err := r.compile(); (err != nil) != tt.err {
+			t.Errorf("compile(%q) = %v, want error %v", tt.pattern, err, tt.err)
+		}
+	}
+}
+
+func TestExcluded(t *testing.T) {
+	bf, _ := newTestFilter(t, "BTCUSDT", "BTCUPUSDT", "ETHUSDT", "SOLUSDT", "EURUSDT", "1000SATSUSDT", "JUPUSDT")
+	bf.router.Access.Seed("telegram:1=operator")
+	for _, line := range []string{"/rule add @leveraged", "/rule add /^1000/ spot", "/rule add @STABLECOINS UP", "/rule add sol? futures"} {
+		if err := run(t, bf, "telegram:1", line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := len(bf.rules.Rules); got != 6 {
+		t.Fatalf("%d rules, want the 2 default ones and 4 added", got)
+	}
+
+	tests := []struct {
+		channel string
+		symbol  string
+		want    bool
+	}{
+		// default rules, BTC and ETH in the futures trades
+		{FBUY, "BTCUSDT", true},
+		{BUY, "BTCUSDT", false},
+		{FSELL, "ETHUSDT", true},
+		// categories, a leveraged token needs its underlying listed
+		{UP, "BTCUPUSDT", true},
+		{UP, "JUPUSDT", false},
+		{UP, "EURUSDT", true},
+		{DOWN, "EURUSDT", false},
+		// regexp in spot
+		{SELL, "1000SATSUSDT", true},
+		{FSELL, "1000SATSUSDT", false},
+		// glob in futures
+		{FBUY, "SOLUSDT", false},
+	}
+	for _, tt := range tests {
+		if got := bf.excluded(tt.channel, tt.symbol); got != tt.want {
+			t.Errorf("excluded(%s, %s) = %v, want %v", tt.channel, tt.symbol, got, tt.want)
+		}
+	}
+
+	// the rules persist and the default ones stay removed
+	run(t, bf, "telegram:1", "/rule remove 1")
+	loaded := newRules()
+	if err := loaded.load(); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.String() != bf.rules.String() || loaded.Rules[0].ID != 2 {
+		t.Errorf("loaded rules %q, want %q", loaded, bf.rules)
+	}
+}
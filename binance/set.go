@@ -0,0 +1,55 @@
+package filter
+
+import (
+	"sort"
+	"sync"
+)
+
+// keySet of keys, e.g. the ignored symbols, read by the streams while the commands write it
+type keySet struct {
+	mu   sync.RWMutex
+	keys map[string]struct{}
+}
+
+func newKeySet() *keySet {
+	return &keySet{keys: make(map[string]struct{})}
+}
+
+func (s *keySet) add(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key] = struct{}{}
+}
+
+// remove key, false if it was not in the set
+func (s *keySet) remove(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, found := s.keys[key]
+	delete(s.keys, key)
+	return found
+}
+
+func (s *keySet) has(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, found := s.keys[key]
+	return found
+}
+
+// list the keys sorted
+func (s *keySet) list() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ret := make([]string, 0, len(s.keys))
+	for key := range s.keys {
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
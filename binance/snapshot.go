@@ -0,0 +1,121 @@
+package filter
+
+import (
+	"encoding/gob"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	snapshotFile     = "snapshot.gob"
+	snapshotInterval = 5 * time.Minute
+	snapshotMaxAge   = 15 * time.Minute
+	snapshotTail     = 10 * milliInMin // samples kept besides the candles
+)
+
+// snapshot of the market state for warm restarts, younger than the backfill
+// so that the klines fill the gap since the snapshot
+type snapshot struct {
+	Time    int64
+	Market  map[string]historySnapshot
+	Alert   map[string]alertdata
+	Funding map[string]float64
+}
+
+func (bf *BinanceFilter) handleSnapshot() {
+	ticker := time.NewTicker(snapshotInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := bf.saveSnapshot(); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (bf *BinanceFilter) saveSnapshot() error {
+	s := snapshot{
+		Time:    time.Now().UnixMilli(),
+		Market:  make(map[string]historySnapshot, len(bf.market)),
+		Alert:   make(map[string]alertdata, len(bf.alert)),
+		Funding: make(map[string]float64, len(bf.funding)),
+	}
+	for symbol, history := range bf.market {
+		s.Market[symbol] = history.snapshot(snapshotTail)
+	}
+	bf.alertMu.Lock()
+	for symbol, alert := range bf.alert {
+		s.Alert[symbol] = *alert
+	}
+	bf.alertMu.Unlock()
+	for symbol, funding := range bf.funding {
+		s.Funding[symbol] = funding.Load()
+	}
+
+	path := statePath(snapshotFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	if err := gob.NewEncoder(file).Encode(&s); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// restoreSnapshot load the market state if the snapshot is recent enough
+func (bf *BinanceFilter) restoreSnapshot() error {
+	file, err := os.Open(statePath(snapshotFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var s snapshot
+	if err := gob.NewDecoder(file).Decode(&s); err != nil {
+		return err
+	}
+
+	age := time.Since(time.UnixMilli(s.Time))
+	if age > snapshotMaxAge {
+		log.Printf("Snapshot too old: %s\n", age.Truncate(time.Second))
+		return nil
+	}
+
+	count := 0
+	for symbol, history := range s.Market {
+		if _, found := bf.market[symbol]; found {
+			bf.market[symbol].restore(history)
+			count++
+		}
+	}
+	for symbol, alert := range s.Alert {
+		if _, found := bf.alert[symbol]; found {
+			*bf.alert[symbol] = alert
+		}
+	}
+	for symbol, funding := range s.Funding {
+		if _, found := bf.funding[symbol]; found {
+			bf.funding[symbol].Store(funding)
+		}
+	}
+
+	log.Printf("Restored %d symbols from snapshot of %s ago\n", count, age.Truncate(time.Second))
+	return nil
+}
@@ -0,0 +1,49 @@
+package filter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// statePath of a persisted file in DATA_LOCATION
+func statePath(name string) string {
+	location := os.Getenv("DATA_LOCATION")
+	if location == "" {
+		location = "data"
+	}
+
+	return filepath.Join(location, name)
+}
+
+// saveState write v as JSON, replacing the previous file atomically
+func saveState(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := statePath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// loadState read JSON into v, a missing file is not an error
+func loadState(name string, v interface{}) error {
+	data, err := os.ReadFile(statePath(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,99 @@
+package filter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"alertbot/record"
+)
+
+const alertsFile = "alerts.db"
+
+func openStore() (*record.Store, error) {
+	path := statePath(alertsFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	return record.Open(path)
+}
+
+// parseSince parse "today", "7d" or a duration into a start time in milliseconds
+func (bf *BinanceFilter) parseSince(s string) (int64, error) {
+	now := time.Now().In(bf.localTime)
+	if strings.ToLower(s) == "today" {
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, bf.localTime).UnixMilli(), nil
+	}
+
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.ParseUint(strings.TrimSuffix(s, "d"), 10, 16)
+		if err != nil || days == 0 {
+			return 0, fmt.Errorf("wrong format")
+		}
+		return now.AddDate(0, 0, -int(days)).UnixMilli(), nil
+	}
+
+	window, err := time.ParseDuration(s)
+	if err != nil || window <= 0 {
+		return 0, fmt.Errorf("wrong format")
+	}
+
+	return now.Add(-window).UnixMilli(), nil
+}
+
+// parseTarget parse a channel or a symbol base into a query
+func (bf *BinanceFilter) parseTarget(s string, q *record.Query) bool {
+	name := strings.ToUpper(s)
+	if name == ALL {
+		return true
+	}
+
+	if _, found := bf.channel[name]; found {
+		q.Channel = name
+		return true
+	}
+
+	if symbol, found := bf.symbolOf(name); found {
+		q.Symbol = symbol
+		return true
+	}
+
+	return false
+}
+
+// score of an alert to rank it, the value of trades and the rate of UP/DOWN
+func score(a *record.Alert) float64 {
+	if a.Channel == UP || a.Channel == DOWN {
+		if a.Rate < 0 {
+			return -a.Rate
+		}
+		return a.Rate
+	}
+
+	return a.Value
+}
+
+func (bf *BinanceFilter) formatRecord(a *record.Alert) string {
+	ret := fmt.Sprintf("%s #%s #%s(%s) P: %s R: %0.2f",
+		time.UnixMilli(a.Time).In(bf.localTime).Format("01-02 15:04:05"), a.Channel, bf.short(a.Symbol), a.Market,
+		strconv.FormatFloat(a.Price, 'f', -1, 64), a.Rate)
+	if a.Channel == UP || a.Channel == DOWN {
+		return ret + fmt.Sprintf("(%d)", a.Number)
+	}
+
+	return ret + bf.printer.Sprintf(" V: %d", int64(a.Value))
+}
+
+func rankAlerts(alerts []record.Alert, top int) []record.Alert {
+	sort.SliceStable(alerts, func(i, j int) bool { return score(&alerts[i]) > score(&alerts[j]) })
+	if len(alerts) > top {
+		alerts = alerts[:top]
+	}
+
+	return alerts
+}
@@ -0,0 +1,413 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/atomic"
+
+	"alertbot/chat"
+	"alertbot/command"
+	"alertbot/record"
+)
+
+const subscriptionStateFile = "subscriptions.json"
+
+// subscriptionThresholds can be overridden per subscription, they are checked against the alerts
+var subscriptionThresholds = []string{"srate", "frate", "slarge", "flarge", "up", "down", "volume"}
+
+// subscription of a user, empty Channels or Symbols match any
+type subscription struct {
+	Chat       string             `json:",omitempty"` // the alerts go to, the chat subscribed from
+	Channels   []string           `json:",omitempty"`
+	Symbols    []string           `json:",omitempty"`
+	Thresholds map[string]float64 `json:",omitempty"`
+}
+
+// to the chat of the subscription of key, the key itself for the ones made before Chat
+func (s *subscription) to(key string) string {
+	if s.Chat != "" {
+		return s.Chat
+	}
+	return key
+}
+
+// subscriber of a request, its user so that in a group each member has their own subscription,
+// the chat when the messenger gives no user
+func subscriber(r chat.Request) string {
+	if _, id, _ := strings.Cut(r.User, ":"); id != "" {
+		return r.User
+	}
+	return r.From
+}
+
+func (s *subscription) matches(channel string, symbol string) bool {
+	return (len(s.Channels) == 0 || contains(s.Channels, channel)) && (len(s.Symbols) == 0 || contains(s.Symbols, symbol))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// subscriptions keeps the persisted subscriptions by user, e.g. "telegram:123"
+type subscriptions struct {
+	mu    sync.RWMutex
+	Chats map[string]*subscription
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{Chats: make(map[string]*subscription)}
+}
+
+func (s *subscriptions) load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return loadState(subscriptionStateFile, s)
+}
+
+// update the subscription of a user, creating it if needed
+func (s *subscriptions) update(chat string, f func(sub *subscription)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.Chats[chat]; !found {
+		s.Chats[chat] = &subscription{Thresholds: make(map[string]float64)}
+	}
+	if s.Chats[chat].Thresholds == nil {
+		s.Chats[chat].Thresholds = make(map[string]float64)
+	}
+	f(s.Chats[chat])
+
+	return saveState(subscriptionStateFile, s)
+}
+
+func (s *subscriptions) remove(chat string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.Chats[chat]; !found {
+		return false, nil
+	}
+
+	delete(s.Chats, chat)
+	return true, saveState(subscriptionStateFile, s)
+}
+
+// threshold of a symbol, the most sensitive of the subscriptions to it or the given one
+func (s *subscriptions) threshold(symbol string, key string, ret float64) float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, sub := range s.Chats {
+		value, found := sub.Thresholds[key]
+		if !found || len(sub.Symbols) > 0 && !contains(sub.Symbols, symbol) {
+			continue
+		}
+
+		if key == "down" {
+			if value > ret {
+				ret = value
+			}
+		} else if value < ret {
+			ret = value
+		}
+	}
+
+	return ret
+}
+
+// recipients of an alert on symbol, the chats subscribed to it whose thresholds it passes
+func (s *subscriptions) recipients(a *record.Alert, symbol string, shared func(key string) float64) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ret := []string{}
+	for key, sub := range s.Chats {
+		if !sub.matches(a.Channel, symbol) {
+			continue
+		}
+
+		if passes(a, func(key string) float64 {
+			if value, found := sub.Thresholds[key]; found {
+				return value
+			}
+			return shared(key)
+		}) && !contains(ret, sub.to(key)) {
+			ret = append(ret, sub.to(key))
+		}
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
+// priority of an alert, how far it exceeds the thresholds of its channel
+func priority(a *record.Alert, threshold func(key string) float64) float64 {
+	switch a.Channel {
+	case UP:
+		return a.Rate / threshold("up")
+	case DOWN:
+		return a.Rate / threshold("down")
+	case BUY, SELL:
+		return math.Max(a.Rate/threshold("srate"), a.Value/threshold("slarge"))
+	case FBUY, FSELL:
+		return math.Max(a.Rate/threshold("frate"), a.Value/threshold("flarge"))
+	}
+	return 0
+}
+
+// passes reports whether an alert reaches the thresholds of its channel
+func passes(a *record.Alert, threshold func(key string) float64) bool {
+	switch a.Channel {
+	case UP:
+		return a.Rate >= threshold("up") && a.VolumeRate >= threshold("volume")
+	case DOWN:
+		return a.Rate <= threshold("down") && a.VolumeRate >= threshold("volume")
+	case BUY, SELL:
+		return a.Rate >= threshold("srate") || a.Value >= threshold("slarge")
+	case FBUY, FSELL:
+		return a.Rate >= threshold("frate") || a.Value >= threshold("flarge")
+	}
+	return true
+}
+
+func formatSubscription(chat string, sub *subscription, short func(symbol string) string) string {
+	channels, symbols := "all channels", "all symbols"
+	if len(sub.Channels) > 0 {
+		channels = strings.Join(sub.Channels, " ")
+	}
+	if len(sub.Symbols) > 0 {
+		bases := []string{}
+		for _, symbol := range sub.Symbols {
+			bases = append(bases, short(symbol))
+		}
+		symbols = strings.Join(bases, " ")
+	}
+
+	keys := []string{}
+	for key, value := range sub.Thresholds {
+		keys = append(keys, fmt.Sprintf("%s=%g", key, value))
+	}
+	sort.Strings(keys)
+
+	line := fmt.Sprintf("%s: %s, %s", chat, channels, symbols)
+	if sub.to(chat) != chat {
+		line += " in " + sub.to(chat)
+	}
+	if len(keys) > 0 {
+		line += fmt.Sprintf(" [%s]", strings.Join(keys, " "))
+	}
+	return line
+}
+
+func (s *subscriptions) has(chat string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, found := s.Chats[chat]
+	return found
+}
+
+// get the description of the subscription of a chat, naming its symbols with short
+func (s *subscriptions) get(chat string, short func(symbol string) string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sub, found := s.Chats[chat]
+	if !found {
+		return "not subscribed"
+	}
+	return formatSubscription(chat, sub, short)
+}
+
+// format the subscriptions, naming their symbols with short
+func (s *subscriptions) format(short func(symbol string) string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	lines := []string{}
+	for chat, sub := range s.Chats {
+		lines = append(lines, formatSubscription(chat, sub, short))
+	}
+	sort.Strings(lines)
+
+	if len(lines) == 0 {
+		return "no subscription"
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// threshold of a symbol for the shared detection, the most sensitive of the global one,
+// the watchlists and the subscriptions
+func (bf *BinanceFilter) threshold(symbol string, key string, global *atomic.Float64) float64 {
+	return bf.subscriptions.threshold(symbol, key, bf.watchlists.threshold(symbol, key, global))
+}
+
+// sharedThreshold of a symbol without the subscriptions, for the default chat
+func (bf *BinanceFilter) sharedThreshold(symbol string) func(key string) float64 {
+	globals := map[string]*atomic.Float64{
+		"srate":  bf.sRateThreshold,
+		"frate":  bf.fRateThreshold,
+		"slarge": bf.largeSThreshold,
+		"flarge": bf.largeFThreshold,
+		"up":     bf.upThreshold,
+		"down":   bf.downThreshold,
+		"volume": bf.volumeThreshold,
+	}
+
+	return func(key string) float64 {
+		return bf.watchlists.threshold(symbol, key, globals[key])
+	}
+}
+
+// Subscription show the subscription of the user
+func (bf *BinanceFilter) Subscription(c *command.Context) {
+	c.Reply(bf.subscriptions.get(subscriber(c.Request), bf.short))
+}
+
+// Subscriptions list the subscriptions of every user
+func (bf *BinanceFilter) Subscriptions(c *command.Context) {
+	c.Reply(bf.subscriptions.format(bf.short))
+}
+
+// Subscribe the user to channels and symbols, e.g. "UP FSELL SOL"
+func (bf *BinanceFilter) Subscribe(c *command.Context) {
+	channels, symbols, err := bf.subscriptionTargets(c)
+	if err != nil {
+		return
+	}
+
+	if err := bf.subscriptions.update(subscriber(c.Request), func(sub *subscription) {
+		sub.Chat = c.Request.From
+		for _, channel := range channels {
+			if !contains(sub.Channels, channel) {
+				sub.Channels = append(sub.Channels, channel)
+			}
+		}
+		for _, symbol := range symbols {
+			if !contains(sub.Symbols, symbol) {
+				sub.Symbols = append(sub.Symbols, symbol)
+			}
+		}
+		sort.Strings(sub.Channels)
+		sort.Strings(sub.Symbols)
+	}); err != nil {
+		c.Reply(err.Error())
+		return
+	}
+
+	c.Reply(bf.subscriptions.get(subscriber(c.Request), bf.short))
+}
+
+// Unsubscribe the user from channels and symbols, from everything without them
+func (bf *BinanceFilter) Unsubscribe(c *command.Context) {
+	if !c.Has("target") {
+		found, err := bf.subscriptions.remove(subscriber(c.Request))
+		if err != nil {
+			c.Reply(err.Error())
+		} else if !found {
+			c.Reply("not subscribed")
+		} else {
+			c.Reply("unsubscribed")
+		}
+		return
+	}
+
+	if !bf.subscriptions.has(subscriber(c.Request)) {
+		c.Reply("not subscribed")
+		return
+	}
+
+	channels, symbols, err := bf.subscriptionTargets(c)
+	if err != nil {
+		return
+	}
+
+	if err := bf.subscriptions.update(subscriber(c.Request), func(sub *subscription) {
+		sub.Channels = without(sub.Channels, channels)
+		sub.Symbols = without(sub.Symbols, symbols)
+	}); err != nil {
+		c.Reply(err.Error())
+		return
+	}
+
+	c.Reply(bf.subscriptions.get(subscriber(c.Request), bf.short))
+}
+
+func without(values []string, removed []string) []string {
+	ret := []string{}
+	for _, v := range values {
+		if !contains(removed, v) {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// subscriptionTargets split the targets into channels and symbols, replying the unknown ones
+func (bf *BinanceFilter) subscriptionTargets(c *command.Context) ([]string, []string, error) {
+	channels, symbols := []string{}, []string{}
+	for _, target := range c.Strings("target") {
+		target = strings.ToUpper(target)
+		if _, found := defaultTemplates[target]; found {
+			channels = append(channels, target)
+			continue
+		}
+
+		if symbol, found := bf.symbolOf(target); found {
+			symbols = append(symbols, symbol)
+			continue
+		}
+
+		c.Invalid("target", fmt.Sprintf("%s is neither an alert channel nor a symbol", target))
+		return nil, nil, errNotFound
+	}
+
+	return channels, symbols, nil
+}
+
+// SubscriptionSet override a threshold for the subscribed user, e.g. "up 1"
+func (bf *BinanceFilter) SubscriptionSet(c *command.Context) {
+	key, threshold := c.String("key"), c.Float("value")
+	if !bf.subscriptions.has(subscriber(c.Request)) {
+		c.Reply("not subscribed, /subscribe add first")
+		return
+	}
+	if (key == "down") != (threshold < 0) || threshold == 0 {
+		c.Invalid("value", "must be negative for down and positive otherwise")
+		return
+	}
+
+	if err := bf.subscriptions.update(subscriber(c.Request), func(sub *subscription) { sub.Thresholds[key] = threshold }); err != nil {
+		c.Reply(err.Error())
+		return
+	}
+
+	c.Reply(bf.subscriptions.get(subscriber(c.Request), bf.short))
+}
+
+// SubscriptionUnset remove a threshold override of the user
+func (bf *BinanceFilter) SubscriptionUnset(c *command.Context) {
+	key := c.String("key")
+	if !bf.subscriptions.has(subscriber(c.Request)) {
+		c.Reply("not subscribed")
+		return
+	}
+
+	if err := bf.subscriptions.update(subscriber(c.Request), func(sub *subscription) { delete(sub.Thresholds, key) }); err != nil {
+		c.Reply(err.Error())
+		return
+	}
+
+	c.Reply(bf.subscriptions.get(subscriber(c.Request), bf.short))
+}
@@ -0,0 +1,118 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"alertbot/record"
+)
+
+// postUp an UP alert of SOLUSDT at rate
+func postUp(bf *BinanceFilter, rate float64) {
+	bf.postAlert(bf.templates.Alert(&record.Alert{Channel: UP, Symbol: "SOLUSDT", Base: "SOL", Quote: "USDT", Market: "S",
+		Price: 100, Rate: rate, VolumeRate: 10, Time: time.Now().UnixMilli()}), nil)
+}
+
+func TestPostAlertFanOut(t *testing.T) {
+	bf, p := newTestFilter(t, "SOLUSDT")
+	bf.DefaultChats("telegram:1")
+	bf.setConfiguration("up", 2)
+	bf.setConfiguration("volume", 1)
+	bf.setConfiguration("priority", 5)
+	bf.throttle.setChannelCooldown(UP, 10*milliInMin)
+
+	// the default chat subscribed to everything, another chat at a lower threshold
+	bf.subscriptions.update("telegram:1", func(sub *subscription) {})
+	bf.subscriptions.update("telegram:2", func(sub *subscription) { sub.Thresholds["up"] = 1 })
+
+	// below the shared threshold, for the second chat only and throttled for it
+	postUp(bf, 1.5)
+	postUp(bf, 1.6)
+	if len(p.to("")) != 0 || len(p.to("telegram:1")) != 0 || len(p.to("telegram:2")) != 1 {
+		t.Fatalf("sent %v, want one alert to telegram:2", p.messages)
+	}
+
+	// the shared alert is sent once to the default chat, its priority against the shared threshold
+	bf.throttle.setChannelCooldown(UP, 0)
+	postUp(bf, 3)
+	if len(p.to("")) != 1 || len(p.to("telegram:1")) != 0 || len(p.to("telegram:2")) != 2 {
+		t.Fatalf("sent %v, want one alert to the default chat and telegram:2", p.messages)
+	}
+	if a := p.to("")[0].Alert; a.Priority != 1.5 || !p.to("")[0].Silent {
+		t.Errorf("priority %v silent %v, want 1.5 below the priority threshold", a.Priority, p.to("")[0].Silent)
+	}
+
+	// the quiet hours defer the default chat only
+	now := time.Now().In(bf.localTime)
+	bf.quiet.set(now.Add(-time.Hour).Format("15:04"), now.Add(time.Hour).Format("15:04"))
+	postUp(bf, 3)
+	if len(p.to("")) != 1 || len(p.to("telegram:2")) != 3 {
+		t.Errorf("sent %v during the quiet hours, want the alert to telegram:2 only", p.messages)
+	}
+	if len(bf.quiet.flush()) != 1 {
+		t.Error("alert not deferred for the default chat")
+	}
+}
+
+func TestSubscriptionSet(t *testing.T) {
+	bf, _ := newTestFilter(t, "SOLUSDT")
+	bf.router.Access.Seed("telegram:1=operator,telegram:2=viewer")
+
+	// a subscription with a target first
+	run(t, bf, "telegram:1", "/subscribe set up 1")
+	if bf.subscriptions.has("telegram:1") {
+		t.Error("/subscribe set created a subscription to everything")
+	}
+
+	run(t, bf, "telegram:1", "/subscribe add SOL")
+	run(t, bf, "telegram:1", "/subscribe set up 1")
+	if got := bf.subscriptions.threshold("SOLUSDT", "up", 2); got != 1 {
+		t.Errorf("up threshold = %v, want 1", got)
+	}
+
+	// below operator, the thresholds stay
+	run(t, bf, "telegram:2", "/subscribe add SOL")
+	if err := run(t, bf, "telegram:2", "/subscribe set up 0.5"); err == nil {
+		t.Error("viewer allowed to set a threshold")
+	}
+	if got := bf.subscriptions.threshold("SOLUSDT", "up", 2); got != 1 {
+		t.Errorf("up threshold = %v after a viewer set it, want 1", got)
+	}
+	bf.subscriptions.update("telegram:2", func(sub *subscription) { sub.Thresholds["up"] = 0.5 })
+	if err := run(t, bf, "telegram:2", "/subscribe unset up"); err == nil {
+		t.Error("viewer allowed to unset a threshold")
+	}
+	if got := bf.subscriptions.threshold("SOLUSDT", "up", 2); got != 0.5 {
+		t.Errorf("up threshold = %v after a viewer unset it, want 0.5", got)
+	}
+}
+
+func TestSubscriptionPerUser(t *testing.T) {
+	bf, p := newTestFilter(t, "SOLUSDT")
+	bf.DefaultChats("telegram:1")
+	bf.setConfiguration("up", 2)
+	bf.setConfiguration("volume", 1)
+	bf.router.Access.Seed("telegram:2=operator,telegram:3=operator")
+
+	// two members of a group, only the second one lowers their threshold
+	runIn(t, bf, "telegram:-100", "telegram:2", "/subscribe add SOL")
+	runIn(t, bf, "telegram:-100", "telegram:3", "/subscribe add SOL")
+	runIn(t, bf, "telegram:-100", "telegram:3", "/subscribe set up 1")
+	if sub := bf.subscriptions.Chats["telegram:2"]; sub == nil || len(sub.Thresholds) != 0 {
+		t.Fatalf("subscription of telegram:2 = %+v, want no override", sub)
+	}
+
+	// the alert goes once to the group, for the second member, after the replies to the commands
+	replies := len(p.to("telegram:-100"))
+	postUp(bf, 1.5)
+	if len(p.to("telegram:-100")) != replies+1 || len(p.to("")) != 0 {
+		t.Errorf("sent %v, want one alert to the group", p.messages)
+	}
+
+	runIn(t, bf, "telegram:-100", "telegram:3", "/subscribe unset up")
+	replies = len(p.to("telegram:-100"))
+	postUp(bf, 1.6)
+	if len(p.to("telegram:-100")) != replies {
+		t.Errorf("sent %v after the override was removed, want no other alert", p.messages)
+	}
+}
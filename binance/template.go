@@ -0,0 +1,72 @@
+package filter
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"alertbot/command"
+)
+
+const templateStateFile = "templates.json"
+
+const (
+	updownTemplate = `<b>#{{.Channel}}({{.Number}}) #{{base .Symbol}}({{.Market}})</b>: <u>{{rate .Rate}}-{{rate .VolumeRate}}</u> P: <u>{{price .Price}}</u> V: {{amount .Value}} T: {{time .Time}}`
+	tradeTemplate  = `<b>#{{.Channel}} #{{base .Symbol}}({{.Market}})</b> <u>{{rate .Rate}}</u> P: <u>{{price .Price}}</u> V: {{amount .Value}} Q: {{amount .Quantity}} {{time .Time}}`
+	futureTemplate = `<b>#{{.Channel}} #{{base .Symbol}}({{.Market}}) #R{{round .Rate}}</b> <u>{{rate .Rate}}</u> P: <u>{{price .Price}}</u> V: {{amount .Value}} Q: {{amount .Quantity}} {{time .Time}}`
+)
+
+var defaultTemplates = map[string]string{
+	UP:    updownTemplate,
+	DOWN:  updownTemplate,
+	BUY:   tradeTemplate,
+	SELL:  tradeTemplate,
+	FBUY:  futureTemplate,
+	FSELL: futureTemplate,
+}
+
+// loadTemplates restore the custom templates
+func (bf *BinanceFilter) loadTemplates() error {
+	custom := map[string]string{}
+	if err := loadState(templateStateFile, &custom); err != nil {
+		return err
+	}
+
+	for channel, text := range custom {
+		if err := bf.templates.Set(channel, text); err != nil {
+			return fmt.Errorf("template %s: %w", channel, err)
+		}
+	}
+
+	return nil
+}
+
+// Template set the alert template of a channel, e.g. "UP <b>#{{.Channel}} {{base .Symbol}}</b> {{rate .Rate}}", "UP reset"
+func (bf *BinanceFilter) Template(c *command.Context) {
+	if !c.Has("channel") {
+		c.Reply(bf.templates.String())
+		return
+	}
+
+	channel, text := strings.ToUpper(c.String("channel")), c.String("template")
+	if text == "" {
+		c.Invalid("template", "missing")
+		return
+	}
+
+	if strings.ToLower(text) == "reset" {
+		text = ""
+	}
+
+	if err := bf.templates.Set(channel, text); err != nil {
+		c.Reply(html.EscapeString(err.Error()))
+		return
+	}
+
+	if err := saveState(templateStateFile, bf.templates.Custom()); err != nil {
+		c.Reply(err.Error())
+		return
+	}
+
+	c.Reply(bf.templates.String())
+}
@@ -0,0 +1,180 @@
+package filter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// throttleKey of the last alert of a symbol in a channel, to a subscribed chat or to every chat
+type throttleKey struct {
+	chat    string
+	channel string
+	symbol  string
+}
+
+// throttle keeps per symbol/channel cooldowns and hourly budgets
+type throttle struct {
+	mu sync.Mutex
+
+	channelCooldown map[string]int64 // channel -> milliseconds
+	symbolCooldown  map[string]int64 // symbol -> milliseconds
+	budget          map[string]int   // channel -> alerts per hour, 0 is unlimited
+
+	last       map[throttleKey]int64 // last alert time
+	since      int64                 // start of the budget hour
+	sent       map[string]int
+	suppressed map[string]int
+}
+
+// newThrottle whose first budget hour starts now
+func newThrottle(now int64) *throttle {
+	return &throttle{
+		channelCooldown: make(map[string]int64),
+		symbolCooldown:  make(map[string]int64),
+		budget:          make(map[string]int),
+		last:            make(map[throttleKey]int64),
+		since:           now,
+		sent:            make(map[string]int),
+		suppressed:      make(map[string]int),
+	}
+}
+
+// allow reports whether an alert may be sent and records it
+func (t *throttle) allow(channel string, symbol string, priority float64, bypass float64, now int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := throttleKey{channel: channel, symbol: symbol}
+	if last, found := t.last[key]; found && now < last+t.cooldown(channel, symbol) {
+		t.suppressed[channel]++
+		return false
+	}
+
+	if budget := t.budget[channel]; budget > 0 && t.sent[channel] >= budget && priority < bypass {
+		t.suppressed[channel]++
+		return false
+	}
+
+	t.last[key] = now
+	t.sent[channel]++
+	return true
+}
+
+// allowChat reports whether an alert below the shared thresholds may be sent to a subscribed chat
+// and records it, under the cooldowns only as the budgets are for the shared alerts
+func (t *throttle) allowChat(chat string, channel string, symbol string, now int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := throttleKey{chat: chat, channel: channel, symbol: symbol}
+	if last, found := t.last[key]; found && now < last+t.cooldown(channel, symbol) {
+		return false
+	}
+
+	t.last[key] = now
+	return true
+}
+
+// cooldown of a symbol in a channel, the longest of both
+func (t *throttle) cooldown(channel string, symbol string) int64 {
+	if t.symbolCooldown[symbol] > t.channelCooldown[channel] {
+		return t.symbolCooldown[symbol]
+	}
+	return t.channelCooldown[channel]
+}
+
+// rollover starts a new budget hour and returns the suppressed counters of the previous one
+func (t *throttle) rollover(now int64) map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now < t.since+milliInHour {
+		return nil
+	}
+
+	suppressed := t.suppressed
+	t.since += (now - t.since) / milliInHour * milliInHour
+	t.sent = make(map[string]int)
+	t.suppressed = make(map[string]int)
+
+	for key, last := range t.last {
+		if now-last > milliInDay {
+			delete(t.last, key)
+		}
+	}
+
+	return suppressed
+}
+
+func (t *throttle) setChannelCooldown(channel string, cooldown int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if cooldown == 0 {
+		delete(t.channelCooldown, channel)
+	} else {
+		t.channelCooldown[channel] = cooldown
+	}
+}
+
+func (t *throttle) setSymbolCooldown(symbol string, cooldown int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if cooldown == 0 {
+		delete(t.symbolCooldown, symbol)
+	} else {
+		t.symbolCooldown[symbol] = cooldown
+	}
+}
+
+func (t *throttle) setBudget(channel string, budget int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if budget == 0 {
+		delete(t.budget, channel)
+	} else {
+		t.budget[channel] = budget
+	}
+}
+
+func (t *throttle) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lines := []string{}
+	for channel, cooldown := range t.channelCooldown {
+		lines = append(lines, fmt.Sprintf("Cooldown %s: %0.2f minute(s)", channel, float64(cooldown)/float64(milliInMin)))
+	}
+	for symbol, cooldown := range t.symbolCooldown {
+		lines = append(lines, fmt.Sprintf("Cooldown %s: %0.2f minute(s)", symbol, float64(cooldown)/float64(milliInMin)))
+	}
+	for channel, budget := range t.budget {
+		lines = append(lines, fmt.Sprintf("Budget %s: %d/%d per hour", channel, t.sent[channel], budget))
+	}
+	sort.Strings(lines)
+
+	if len(lines) == 0 {
+		return "no cooldown or budget"
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func formatSuppressed(suppressed map[string]int) string {
+	channels := make([]string, 0, len(suppressed))
+	for channel := range suppressed {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+
+	ret := "Suppressed last hour:"
+	for _, channel := range channels {
+		ret += fmt.Sprintf(" %s %d", channel, suppressed[channel])
+	}
+
+	return ret
+}
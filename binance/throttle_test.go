@@ -0,0 +1,77 @@
+package filter
+
+import "testing"
+
+func TestThrottleCooldown(t *testing.T) {
+	th := newThrottle(0)
+	th.setChannelCooldown(BUY, 10*milliInMin)
+	th.setSymbolCooldown("SOLUSDT", 30*milliInMin)
+
+	tests := []struct {
+		channel string
+		symbol  string
+		now     int64
+		want    bool
+	}{
+		{BUY, "EOSUSDT", 0, true},
+		{BUY, "EOSUSDT", 5 * milliInMin, false},
+		{SELL, "EOSUSDT", 5 * milliInMin, true}, // no cooldown on SELL
+		{BUY, "EOSUSDT", 10 * milliInMin, true},
+		{BUY, "SOLUSDT", 10 * milliInMin, true},
+		{BUY, "SOLUSDT", 30 * milliInMin, false}, // the symbol cooldown is longer
+		{BUY, "SOLUSDT", 40 * milliInMin, true},
+		// keys do not collide when channel+symbol concatenate alike
+		{"B", "UYEOSUSDT", 40 * milliInMin, true},
+	}
+
+	for _, tt := range tests {
+		if got := th.allow(tt.channel, tt.symbol, 1, 3, tt.now); got != tt.want {
+			t.Errorf("allow(%s, %s, %d) = %v, want %v", tt.channel, tt.symbol, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestThrottleBudget(t *testing.T) {
+	th := newThrottle(0)
+	th.setBudget(UP, 2)
+
+	for i, want := range []bool{true, true, false} {
+		if got := th.allow(UP, "EOSUSDT", 1, 3, int64(i)); got != want {
+			t.Errorf("alert %d allowed = %v, want %v", i, got, want)
+		}
+	}
+
+	// a high priority alert bypasses the budget
+	if !th.allow(UP, "EOSUSDT", 5, 3, 3) {
+		t.Error("high priority alert suppressed")
+	}
+}
+
+func TestThrottleRollover(t *testing.T) {
+	start := int64(30 * milliInMin) // mid-hour
+	th := newThrottle(start)
+	th.setBudget(UP, 1)
+	th.allow(UP, "EOSUSDT", 1, 3, start)
+	th.allow(UP, "EOSUSDT", 1, 3, start+1)
+
+	// the first hour starts at process start, not at the clock hour
+	if suppressed := th.rollover(milliInHour); suppressed != nil {
+		t.Fatalf("rollover before an hour since start = %v", suppressed)
+	}
+
+	suppressed := th.rollover(start + milliInHour)
+	if suppressed[UP] != 1 {
+		t.Fatalf("suppressed = %v, want UP 1", suppressed)
+	}
+	if !th.allow(UP, "EOSUSDT", 1, 3, start+milliInHour) {
+		t.Error("budget not reset after rollover")
+	}
+
+	// the next hour keeps the start alignment
+	if suppressed := th.rollover(start + 2*milliInHour - 1); suppressed != nil {
+		t.Errorf("rollover within the second hour = %v", suppressed)
+	}
+	if suppressed := th.rollover(start + 2*milliInHour); suppressed == nil {
+		t.Error("no rollover after the second hour")
+	}
+}
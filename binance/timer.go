@@ -0,0 +1,125 @@
+package filter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	expiryInterval = 10 * time.Second // between the checks of the lapsed timers
+	expiryUser     = "expiry"
+)
+
+// timers keeps keys, e.g. symbols or channels, active until a time
+type timers struct {
+	mu    sync.Mutex
+	until map[string]int64 // key -> milliseconds
+}
+
+func newTimers() *timers {
+	return &timers{until: make(map[string]int64)}
+}
+
+// set the end of a timer, zero removes it
+func (t *timers) set(key string, until int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if until == 0 {
+		delete(t.until, key)
+	} else {
+		t.until[key] = until
+	}
+}
+
+// active reports whether the timer of key runs at now
+func (t *timers) active(key string, now int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	until, found := t.until[key]
+	return found && now < until
+}
+
+// remaining time of the timer of key, false without a timer
+func (t *timers) remaining(key string, now int64) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	until, found := t.until[key]
+	if !found || now >= until {
+		return 0, false
+	}
+
+	return time.Duration(until-now) * time.Millisecond / time.Second * time.Second, true
+}
+
+// lapsed timers at now, they are removed
+func (t *timers) lapsed(now int64) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ret := []string{}
+	for key, until := range t.until {
+		if now >= until {
+			ret = append(ret, key)
+			delete(t.until, key)
+		}
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
+// format the running timers, e.g. "SOLUSDT snoozed for 15m0s", empty if none
+func (t *timers) format(verb string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	lines := []string{}
+	for key, until := range t.until {
+		if now < until {
+			lines = append(lines, fmt.Sprintf("%s %s for %s", key, verb, time.Duration(until-now)*time.Millisecond/time.Second*time.Second))
+		}
+	}
+	sort.Strings(lines)
+
+	return strings.Join(lines, "\n")
+}
+
+// handleExpiries lift the lapsed mutes, ignores and snoozes with a SYSTEM notice
+func (bf *BinanceFilter) handleExpiries() {
+	ticker := time.NewTicker(expiryInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		ms := now.UnixMilli()
+		for _, channel := range bf.mutes.lapsed(ms) {
+			if bf.channel[channel].Load() {
+				continue
+			}
+
+			before := bf.muted()
+			bf.channel[channel].Store(true)
+			bf.recordChange(expiryUser, "unmute", channel, before, bf.muted())
+			bf.postMessage(SYSTEM, fmt.Sprintf("%s unmuted, the mute lapsed", channel))
+		}
+
+		for _, symbol := range bf.ignores.lapsed(ms) {
+			if !bf.ignored.remove(symbol) {
+				continue
+			}
+
+			bf.recordChange(expiryUser, "unignore", symbol, ignoredState, "")
+			bf.postMessage(SYSTEM, fmt.Sprintf("%s unignored, the ignore lapsed", symbol))
+		}
+
+		for _, symbol := range bf.snoozes.lapsed(ms) {
+			bf.postMessage(SYSTEM, fmt.Sprintf("%s unsnoozed, the snooze lapsed", symbol))
+		}
+	}
+}
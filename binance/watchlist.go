@@ -0,0 +1,331 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/atomic"
+)
+
+const watchlistStateFile = "watchlists.json"
+
+// watchlistThresholds can be overridden per watchlist, the most sensitive value wins
+var watchlistThresholds = map[string]struct{}{
+	"srate":     {},
+	"frate":     {},
+	"minvolume": {},
+	"maxvolume": {},
+	"slarge":    {},
+	"flarge":    {},
+	"up":        {},
+	"down":      {},
+	"volume":    {},
+}
+
+type watchlist struct {
+	symbols    map[string]struct{}
+	thresholds map[string]float64
+	route      string
+}
+
+// watchlistState is the persisted form of a watchlist
+type watchlistState struct {
+	Symbols    []string
+	Thresholds map[string]float64 `json:",omitempty"`
+	Route      string             `json:",omitempty"`
+}
+
+func (l *watchlist) MarshalJSON() ([]byte, error) {
+	s := watchlistState{Symbols: make([]string, 0, len(l.symbols)), Thresholds: l.thresholds, Route: l.route}
+	for symbol := range l.symbols {
+		s.Symbols = append(s.Symbols, symbol)
+	}
+	sort.Strings(s.Symbols)
+
+	return json.Marshal(s)
+}
+
+func (l *watchlist) UnmarshalJSON(data []byte) error {
+	var s watchlistState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	l.symbols, l.thresholds, l.route = make(map[string]struct{}, len(s.Symbols)), s.Thresholds, s.Route
+	for _, symbol := range s.Symbols {
+		l.symbols[symbol] = struct{}{}
+	}
+	if l.thresholds == nil {
+		l.thresholds = make(map[string]float64)
+	}
+	return nil
+}
+
+// watchlists keeps the persisted named symbol lists and the channels restricted to them
+type watchlists struct {
+	mu         sync.RWMutex
+	Lists      map[string]*watchlist
+	Restricted map[string]string // channel -> watchlist name
+}
+
+func newWatchlists() *watchlists {
+	return &watchlists{
+		Lists:      make(map[string]*watchlist),
+		Restricted: make(map[string]string),
+	}
+}
+
+func (w *watchlists) load() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := loadState(watchlistStateFile, w); err != nil {
+		return err
+	}
+	if w.Lists == nil {
+		w.Lists = make(map[string]*watchlist)
+	}
+	if w.Restricted == nil {
+		w.Restricted = make(map[string]string)
+	}
+	return nil
+}
+
+func (w *watchlists) add(name string, symbols []string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, found := w.Lists[name]; !found {
+		w.Lists[name] = &watchlist{symbols: make(map[string]struct{}), thresholds: make(map[string]float64)}
+	}
+
+	for _, symbol := range symbols {
+		w.Lists[name].symbols[symbol] = struct{}{}
+	}
+	return saveState(watchlistStateFile, w)
+}
+
+// remove symbols from a watchlist, the whole watchlist without symbols
+func (w *watchlists) remove(name string, symbols []string) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, found := w.Lists[name]; !found {
+		return false, nil
+	}
+
+	if len(symbols) == 0 {
+		delete(w.Lists, name)
+		for channel, restriction := range w.Restricted {
+			if restriction == name {
+				delete(w.Restricted, channel)
+			}
+		}
+		return true, saveState(watchlistStateFile, w)
+	}
+
+	for _, symbol := range symbols {
+		delete(w.Lists[name].symbols, symbol)
+	}
+	return true, saveState(watchlistStateFile, w)
+}
+
+func (w *watchlists) setThreshold(name string, key string, value float64) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, found := w.Lists[name]; !found {
+		return false, nil
+	}
+
+	w.Lists[name].thresholds[key] = value
+	return true, saveState(watchlistStateFile, w)
+}
+
+func (w *watchlists) setRoute(name string, route string) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, found := w.Lists[name]; !found {
+		return false, nil
+	}
+
+	w.Lists[name].route = route
+	return true, saveState(watchlistStateFile, w)
+}
+
+func (w *watchlists) restrict(channel string, name string) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if name == "" {
+		delete(w.Restricted, channel)
+		return true, saveState(watchlistStateFile, w)
+	}
+
+	if _, found := w.Lists[name]; !found {
+		return false, nil
+	}
+
+	w.Restricted[channel] = name
+	return true, saveState(watchlistStateFile, w)
+}
+
+func (w *watchlists) clear() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.Restricted = make(map[string]string)
+	return saveState(watchlistStateFile, w)
+}
+
+// restrictions of the channels, e.g. "FSELL=defi UP=majors", for the audit
+func (w *watchlists) restrictions() string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	ret := []string{}
+	for channel, name := range w.Restricted {
+		ret = append(ret, channel+"="+name)
+	}
+	sort.Strings(ret)
+
+	return strings.Join(ret, " ")
+}
+
+// setRestrictions replace the restrictions of the channels, the missing watchlists are skipped
+func (w *watchlists) setRestrictions(s string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.Restricted = make(map[string]string)
+	for _, restriction := range strings.Fields(s) {
+		channel, name, _ := strings.Cut(restriction, "=")
+		if _, found := w.Lists[name]; found {
+			w.Restricted[channel] = name
+		}
+	}
+	return saveState(watchlistStateFile, w)
+}
+
+// threshold of a symbol, the most sensitive of its watchlists or the global one
+func (w *watchlists) threshold(symbol string, key string, global *atomic.Float64) float64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	ret := global.Load()
+	for _, list := range w.Lists {
+		if _, found := list.symbols[symbol]; !found {
+			continue
+		}
+
+		value, found := list.thresholds[key]
+		if !found {
+			continue
+		}
+
+		if key == "down" || key == "maxvolume" {
+			if value > ret {
+				ret = value
+			}
+		} else if value < ret {
+			ret = value
+		}
+	}
+
+	return ret
+}
+
+// allowed reports whether the symbol passes the watchlist restriction of a channel
+func (w *watchlists) allowed(channel string, symbol string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	for _, c := range []string{ALL, channel} {
+		name, found := w.Restricted[c]
+		if !found {
+			continue
+		}
+
+		if _, found := w.Lists[name].symbols[symbol]; !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// route of a symbol, the first routed watchlist by name or empty for the default one
+func (w *watchlists) route(symbol string) string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	route, routeName := "", ""
+	for name, list := range w.Lists {
+		if _, found := list.symbols[symbol]; !found || list.route == "" {
+			continue
+		}
+
+		if routeName == "" || name < routeName {
+			route, routeName = list.route, name
+		}
+	}
+
+	return route
+}
+
+// format the watchlists, naming their symbols with short
+func (w *watchlists) format(short func(symbol string) string) string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	names := make([]string, 0, len(w.Lists))
+	for name := range w.Lists {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{}
+	for _, name := range names {
+		list := w.Lists[name]
+
+		symbols := make([]string, 0, len(list.symbols))
+		for symbol := range list.symbols {
+			symbols = append(symbols, short(symbol))
+		}
+		sort.Strings(symbols)
+
+		keys := make([]string, 0, len(list.thresholds))
+		for key := range list.thresholds {
+			keys = append(keys, fmt.Sprintf("%s=%g", key, list.thresholds[key]))
+		}
+		sort.Strings(keys)
+
+		line := fmt.Sprintf("%s: %s", name, strings.Join(symbols, " "))
+		if len(keys) > 0 {
+			line += fmt.Sprintf(" [%s]", strings.Join(keys, " "))
+		}
+		if list.route != "" {
+			line += fmt.Sprintf(" -> %s", list.route)
+		}
+		lines = append(lines, line)
+	}
+
+	channels := make([]string, 0, len(w.Restricted))
+	for channel := range w.Restricted {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	for _, channel := range channels {
+		lines = append(lines, fmt.Sprintf("%s restricted to %s", channel, w.Restricted[channel]))
+	}
+
+	if len(lines) == 0 {
+		return "no watchlist"
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,35 @@
+package filter
+
+import "testing"
+
+func TestWatchlistsPersist(t *testing.T) {
+	bf, _ := newTestFilter(t, "SOLUSDT", "AVAXUSDT")
+	bf.router.Access.Seed("telegram:1=admin")
+
+	run(t, bf, "telegram:1", "/watch add defi SOL AVAX")
+	run(t, bf, "telegram:1", "/watch set defi up 1")
+	run(t, bf, "telegram:1", "/watch route defi telegram:-100")
+	run(t, bf, "telegram:1", "/filter FSELL defi")
+
+	// the watchlists, their thresholds, route and restrictions persist
+	loaded := newWatchlists()
+	if err := loaded.load(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := loaded.format(bf.short), bf.watchlists.format(bf.short); got != want {
+		t.Errorf("loaded watchlists %q, want %q", got, want)
+	}
+	if !loaded.allowed(FSELL, "SOLUSDT") || loaded.route("AVAXUSDT") != "telegram:-100" {
+		t.Error("loaded watchlists do not restrict nor route")
+	}
+
+	// and so does the removal of one
+	run(t, bf, "telegram:1", "/watch remove defi")
+	loaded = newWatchlists()
+	if err := loaded.load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.format(bf.short); got != "no watchlist" {
+		t.Errorf("loaded watchlists %q after the removal, want none", got)
+	}
+}
@@ -0,0 +1,29 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+
+	"alertbot/format"
+	"alertbot/webhook"
+)
+
+const webhookFile = "webhook.db"
+
+func openWebhook() (*webhook.Sink, error) {
+	path := statePath(webhookFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	return webhook.Open(path)
+}
+
+// postWebhook queue a message, with its alert if any, for the webhook routes
+func (bf *BinanceFilter) postWebhook(msg format.Message) {
+	if bf.webhook == nil {
+		return
+	}
+
+	bf.webhook.Post(msg.Channel, webhook.NewPayload(msg.Channel, msg.Alert, msg.Render(format.Text)))
+}
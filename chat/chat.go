@@ -0,0 +1,10 @@
+// Package chat holds the commands received by the messengers.
+package chat
+
+// Request is a command received by a messenger
+type Request struct {
+	Payload string // arguments of the command
+	Reply   string // text of the message replied to, empty if none
+	From    string // chat of the command, replies are routed to it
+	User    string // sender of the command
+}
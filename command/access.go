@@ -0,0 +1,144 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Role of a user or a chat, a role allows the commands of the lower ones
+type Role int
+
+const (
+	None     Role = iota
+	Viewer        // queries
+	Operator      // mute, ignore, watchlists
+	Admin         // thresholds, restart, access
+)
+
+var roleNames = map[Role]string{None: "none", Viewer: "viewer", Operator: "operator", Admin: "admin"}
+
+func (r Role) String() string {
+	return roleNames[r]
+}
+
+// MarshalText the name of the role
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText a role from its name
+func (r *Role) UnmarshalText(text []byte) error {
+	role, err := ParseRole(string(text))
+	*r = role
+	return err
+}
+
+// ParseRole from its name
+func ParseRole(name string) (Role, error) {
+	for role, n := range roleNames {
+		if n == strings.ToLower(name) && role != None {
+			return role, nil
+		}
+	}
+	return None, fmt.Errorf("unknown role %q", name)
+}
+
+// Access is the allow-list of users and chats, identified as "<messenger>:<id>".
+// Everyone in an allowed chat, e.g. a group, has its role.
+type Access struct {
+	mu      sync.RWMutex
+	seeded  map[string]Role // from the configuration
+	granted map[string]Role // by commands
+}
+
+// NewAccess with an empty allow-list
+func NewAccess() *Access {
+	return &Access{seeded: make(map[string]Role), granted: make(map[string]Role)}
+}
+
+// Seed the allow-list from "telegram:123=admin,telegram:-100456=viewer"
+func (a *Access) Seed(s string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, entry := range strings.Split(s, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		id, name, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found {
+			return fmt.Errorf("wrong access %q", entry)
+		}
+		role, err := ParseRole(name)
+		if err != nil {
+			return err
+		}
+		a.seeded[id] = role
+	}
+
+	return nil
+}
+
+// Grant a role, None revokes it
+func (a *Access) Grant(id string, role Role) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if role == None {
+		delete(a.granted, id)
+	} else {
+		a.granted[id] = role
+	}
+}
+
+// Granted roles, without the configured ones
+func (a *Access) Granted() map[string]Role {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	ret := make(map[string]Role, len(a.granted))
+	for id, role := range a.granted {
+		ret[id] = role
+	}
+	return ret
+}
+
+// Role of a user in a chat, the highest of both
+func (a *Access) Role(user string, chat string) Role {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	ret := None
+	for _, id := range []string{user, chat} {
+		for _, roles := range []map[string]Role{a.seeded, a.granted} {
+			if role := roles[id]; role > ret {
+				ret = role
+			}
+		}
+	}
+
+	return ret
+}
+
+func (a *Access) String() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	lines := []string{}
+	for id, role := range a.seeded {
+		lines = append(lines, fmt.Sprintf("%s: %s (configured)", id, role))
+	}
+	for id, role := range a.granted {
+		lines = append(lines, fmt.Sprintf("%s: %s", id, role))
+	}
+	sort.Strings(lines)
+
+	if len(lines) == 0 {
+		return "no access"
+	}
+
+	return strings.Join(lines, "\n")
+}
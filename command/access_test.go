@@ -0,0 +1,122 @@
+package command
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"alertbot/chat"
+)
+
+func TestSeed(t *testing.T) {
+	tests := []struct {
+		seed string
+		err  bool
+	}{
+		{"", false},
+		{"telegram:1=admin, telegram:-100=Viewer,", false},
+		{"telegram:1", true},
+		{"telegram:1=owner", true},
+		{"telegram:1=none", true},
+	}
+
+	for _, tt := range tests {
+		if err := NewAccess().Seed(tt.seed); (err != nil) != tt.err {
+			t.Errorf("Seed(%q) = %v, want error %v", tt.seed, err, tt.err)
+		}
+	}
+}
+
+func TestRole(t *testing.T) {
+	a := NewAccess()
+	a.Seed("telegram:1=admin,telegram:-100=viewer")
+	a.Grant("telegram:2", Operator)
+	a.Grant("telegram:-100", Operator)
+
+	tests := []struct {
+		user string
+		chat string
+		want Role
+	}{
+		{"telegram:1", "telegram:1", Admin},
+		{"telegram:1", "telegram:-100", Admin}, // the highest of the user and the chat
+		{"telegram:3", "telegram:-100", Operator},
+		{"telegram:2", "telegram:2", Operator},
+		{"telegram:3", "telegram:3", None},
+		{"discord:1", "discord:1", None}, // ids are per messenger
+	}
+	for _, tt := range tests {
+		if got := a.Role(tt.user, tt.chat); got != tt.want {
+			t.Errorf("Role(%s, %s) = %s, want %s", tt.user, tt.chat, got, tt.want)
+		}
+	}
+
+	// revoking a grant leaves the configured role
+	a.Grant("telegram:-100", None)
+	if got := a.Role("telegram:3", "telegram:-100"); got != Viewer {
+		t.Errorf("role after revoke = %s, want viewer", got)
+	}
+	if granted := a.Granted(); len(granted) != 1 || granted["telegram:2"] != Operator {
+		t.Errorf("granted = %v, want telegram:2 only", granted)
+	}
+}
+
+func TestRoleJSON(t *testing.T) {
+	granted := map[string]Role{"telegram:2": Operator}
+	data, err := json.Marshal(granted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"telegram:2":"operator"}` {
+		t.Errorf("marshaled %s", data)
+	}
+
+	restored := map[string]Role{}
+	if err := json.Unmarshal(data, &restored); err != nil || restored["telegram:2"] != Operator {
+		t.Errorf("unmarshaled %v, %v", restored, err)
+	}
+	if err := json.Unmarshal([]byte(`{"telegram:2":"root"}`), &restored); err == nil {
+		t.Error("unknown role unmarshaled")
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	tr := newTestRouter()
+	tr.Access = NewAccess()
+	tr.Access.Seed("telegram:1=viewer,telegram:2=operator")
+	tr.Add(&Command{
+		Names: []string{"/watch"},
+		Subcommands: []*Command{
+			{Names: []string{"show"}, Run: func(c *Context) { tr.ran = "watch show" }},
+			{Names: []string{"add"}, Args: []Arg{{Name: "symbol"}}, Role: Operator, Run: func(c *Context) { tr.ran = "watch add" }},
+		},
+	})
+	tr.Add(&Command{Names: []string{"/restart"}, Role: Admin, Run: func(c *Context) { tr.ran = "restart" }})
+
+	tests := []struct {
+		user    string
+		line    string
+		allowed bool
+		replied bool // the refusal, to known senders only
+	}{
+		{"telegram:1", "/watch show", true, false},
+		{"telegram:1", "/watch add SOL", false, true},
+		{"telegram:2", "/watch add SOL", true, false},
+		{"telegram:2", "/restart", false, true},
+		{"telegram:3", "/watch show", false, false},
+		{"telegram:1", "/watch foo", false, true}, // the usage
+		{"telegram:3", "/watch foo", false, false},
+	}
+
+	for _, tt := range tests {
+		tr.replies, tr.ran = nil, ""
+		name, payload, _ := strings.Cut(tt.line, " ")
+		err := tr.Run(tr.byName[name], chat.Request{Payload: payload, From: tt.user, User: tt.user})
+		if allowed := err == nil && tr.ran != ""; allowed != tt.allowed {
+			t.Errorf("%s %s: allowed %v, want %v", tt.user, tt.line, allowed, tt.allowed)
+		}
+		if replied := len(tr.replies) > 0; replied != tt.replied {
+			t.Errorf("%s %s: replied %v, want %v", tt.user, tt.line, tr.replies, tt.replied)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+// Package command routes messenger commands to handlers with declared arguments,
+// generates their help and replies to the chat the command came from.
+package command
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"alertbot/chat"
+)
+
+// Kind of an argument value
+type Kind int
+
+const (
+	String Kind = iota
+	Int
+	Float
+	Duration // Go duration, "0" is zero
+	Choice   // one of Choices, lower case
+)
+
+// Arg declares an argument of a command
+type Arg struct {
+	Name     string
+	Kind     Kind
+	Choices  []string
+	Optional bool
+	Many     bool                      // takes the remaining words
+	Rest     bool                      // takes the remaining text as is
+	Check    func(string) error        // validates the raw value
+	Fallback func(chat.Request) string // value when missing, e.g. from the message replied to
+}
+
+func (a Arg) usage() string {
+	name := a.Name
+	if a.Kind == Choice {
+		name = strings.Join(a.Choices, "|")
+	}
+	if a.Many {
+		name += "..."
+	}
+
+	if a.Optional {
+		return "[" + name + "]"
+	}
+	return "<" + name + ">"
+}
+
+// Command declares a command, a command with subcommands selects one with its first word
+type Command struct {
+	Names       []string // first one is the canonical name, e.g. "/set", "/s"
+	Summary     string
+	Args        []Arg
+	Subcommands []*Command // names without slash, e.g. "add"
+	Examples    []string
+	Role        Role             // required besides Viewer, a subcommand has its own
+	Run         func(c *Context) // without subcommand or when none is given
+}
+
+// Name of the command, without slash
+func (cmd *Command) Name() string {
+	return strings.TrimPrefix(cmd.Names[0], "/")
+}
+
+// Usage lines of the command
+func (cmd *Command) Usage() string {
+	lines := []string{}
+	if cmd.Run != nil {
+		lines = append(lines, usage(cmd.Names[0], cmd.Args))
+	}
+	for _, sub := range cmd.Subcommands {
+		lines = append(lines, usage(cmd.Names[0]+" "+sub.Names[0], sub.Args))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func usage(name string, args []Arg) string {
+	words := []string{name}
+	for _, a := range args {
+		words = append(words, a.usage())
+	}
+
+	return strings.Join(words, " ")
+}
+
+// ParseError of an argument
+type ParseError struct {
+	Command *Command
+	Arg     string
+	Value   string
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	reason := "wrong format"
+	switch {
+	case e.Err != nil && e.Arg != "" && e.Value != "":
+		reason = fmt.Sprintf("invalid %s %q: %v", e.Arg, e.Value, e.Err)
+	case e.Err != nil && e.Arg != "":
+		reason = fmt.Sprintf("%s: %v", e.Arg, e.Err)
+	case e.Err != nil:
+		reason = e.Err.Error()
+	case e.Arg != "" && e.Value == "":
+		reason = fmt.Sprintf("missing %s", e.Arg)
+	case e.Arg != "":
+		reason = fmt.Sprintf("unknown %s %q", e.Arg, e.Value)
+	}
+
+	return fmt.Sprintf("%s\nUsage:\n%s", reason, e.Command.Usage())
+}
+
+var (
+	errTooMany = errors.New("too many arguments")
+	fields     = regexp.MustCompile(`\S+`)
+)
+
+// Context of a command run
+type Context struct {
+	Request chat.Request
+
+	command *Command
+	values  map[string][]string
+	router  *Router
+}
+
+// Has reports whether the argument is given
+func (c *Context) Has(name string) bool {
+	return len(c.values[name]) > 0
+}
+
+// String value of an argument, empty if missing
+func (c *Context) String(name string) string {
+	if values := c.values[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Strings values of a Many argument
+func (c *Context) Strings(name string) []string {
+	return c.values[name]
+}
+
+// Int value of an argument, zero if missing
+func (c *Context) Int(name string) int64 {
+	v, _ := strconv.ParseInt(c.String(name), 10, 64)
+	return v
+}
+
+// Float value of an argument, zero if missing
+func (c *Context) Float(name string) float64 {
+	v, _ := strconv.ParseFloat(c.String(name), 64)
+	return v
+}
+
+// Duration value of an argument, zero if missing
+func (c *Context) Duration(name string) time.Duration {
+	v, _ := parseDuration(c.String(name))
+	return v
+}
+
+// Reply in HTML to the chat of the command
+func (c *Context) Reply(s string) {
+	c.router.reply(c.Request.From, s)
+}
+
+// ReplyPhoto to the chat of the command
+func (c *Context) ReplyPhoto(caption string, photo []byte) {
+	c.router.photo(c.Request.From, caption, photo)
+}
+
+// Invalid reply the error of an argument and the usage
+func (c *Context) Invalid(arg string, reason string) {
+	c.Reply(escape((&ParseError{Command: c.command, Arg: arg, Value: c.String(arg), Err: errors.New(reason)}).Error()))
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if s == "0" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// parse the payload of cmd into c
+func (c *Context) parse(cmd *Command, payload string) error {
+	c.command, c.values = cmd, make(map[string][]string)
+
+	spans := fields.FindAllStringIndex(payload, -1)
+	i := 0
+	for _, a := range cmd.Args {
+		if i >= len(spans) {
+			if a.Fallback != nil {
+				if v := a.Fallback(c.Request); v != "" {
+					c.values[a.Name] = []string{v}
+					continue
+				}
+			}
+			if !a.Optional {
+				return &ParseError{Command: cmd, Arg: a.Name}
+			}
+			continue
+		}
+
+		values := []string{payload[spans[i][0]:spans[i][1]]}
+		switch {
+		case a.Rest:
+			values, i = []string{strings.TrimSpace(payload[spans[i][0]:])}, len(spans)
+		case a.Many:
+			values = values[:0]
+			for ; i < len(spans); i++ {
+				values = append(values, payload[spans[i][0]:spans[i][1]])
+			}
+		default:
+			i++
+		}
+
+		for j, v := range values {
+			v, err := check(a, v)
+			if err != nil {
+				return &ParseError{Command: cmd, Arg: a.Name, Value: values[j], Err: err}
+			}
+			values[j] = v
+		}
+		c.values[a.Name] = values
+	}
+
+	if i < len(spans) {
+		return &ParseError{Command: cmd, Err: errTooMany}
+	}
+
+	return nil
+}
+
+// check a value of a, returning it normalized
+func check(a Arg, v string) (string, error) {
+	var err error
+	switch a.Kind {
+	case Int:
+		if _, e := strconv.ParseInt(v, 10, 64); e != nil {
+			err = errors.New("not an integer")
+		}
+	case Float:
+		if f, e := strconv.ParseFloat(v, 64); e != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			err = errors.New("not a number")
+		}
+	case Duration:
+		if _, e := parseDuration(v); e != nil {
+			err = errors.New("not a duration, e.g. 30m")
+		}
+	case Choice:
+		v = strings.ToLower(v)
+		err = fmt.Errorf("one of %s", strings.Join(a.Choices, ", "))
+		for _, choice := range a.Choices {
+			if v == choice {
+				err = nil
+			}
+		}
+	}
+	if err != nil {
+		return v, err
+	}
+
+	if a.Check != nil {
+		return v, a.Check(v)
+	}
+	return v, nil
+}
+
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
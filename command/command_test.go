@@ -0,0 +1,185 @@
+package command
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"alertbot/chat"
+)
+
+// testRouter records the replies and the arguments of the last run
+type testRouter struct {
+	*Router
+	replies []string
+	values  map[string][]string
+	ran     string
+}
+
+func newTestRouter() *testRouter {
+	tr := &testRouter{}
+	tr.Router = NewRouter(func(to string, s string) { tr.replies = append(tr.replies, s) }, nil)
+
+	record := func(name string) func(c *Context) {
+		return func(c *Context) { tr.ran, tr.values = name, c.values }
+	}
+	tr.Add(&Command{
+		Names: []string{"/set", "/s"},
+		Args:  []Arg{{Name: "key", Kind: Choice, Choices: []string{"up", "down"}}, {Name: "value", Kind: Float}},
+		Run:   record("set"),
+	})
+	tr.Add(&Command{
+		Names: []string{"/ignore"},
+		Args: []Arg{
+			{Name: "symbol", Fallback: func(r chat.Request) string {
+				if words := strings.Fields(r.Reply); len(words) > 0 {
+					return words[0]
+				}
+				return ""
+			}},
+			{Name: "duration", Kind: Duration, Optional: true},
+		},
+		Run: record("ignore"),
+	})
+	tr.Add(&Command{
+		Names: []string{"/top"},
+		Args: []Arg{{Name: "count", Kind: Int, Optional: true, Check: func(v string) error {
+			if strings.HasPrefix(v, "-") {
+				return errors.New("must be positive")
+			}
+			return nil
+		}}},
+		Run: record("top"),
+	})
+	tr.Add(&Command{
+		Names: []string{"/sub"},
+		Subcommands: []*Command{
+			{Names: []string{"add"}, Args: []Arg{{Name: "target", Many: true, Optional: true}}, Run: record("sub add")},
+			{Names: []string{"set"}, Args: []Arg{{Name: "key"}, {Name: "value", Kind: Float}}, Run: record("sub set")},
+		},
+		Run: record("sub"),
+	})
+	tr.Add(&Command{
+		Names: []string{"/template"},
+		Args:  []Arg{{Name: "channel"}, {Name: "template", Rest: true, Optional: true}},
+		Run:   record("template"),
+	})
+
+	return tr
+}
+
+// run a command line, returning the reply if any
+func (tr *testRouter) run(t *testing.T, line string, reply string) string {
+	tr.replies, tr.values, tr.ran = nil, nil, ""
+
+	name, payload, _ := strings.Cut(line, " ")
+	cmd, found := tr.byName[name]
+	if !found {
+		t.Fatalf("no command %s", name)
+	}
+	if err := tr.Run(cmd, chat.Request{Payload: payload, Reply: reply, From: "telegram:1", User: "telegram:1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Join(tr.replies, "\n")
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line   string
+		reply  string // message replied to
+		ran    string
+		values map[string][]string
+	}{
+		{"/set up 2.5", "", "set", map[string][]string{"key": {"up"}, "value": {"2.5"}}},
+		{"/s DOWN -1", "", "set", map[string][]string{"key": {"down"}, "value": {"-1"}}},
+		{"/ignore SOL 2h", "", "ignore", map[string][]string{"symbol": {"SOL"}, "duration": {"2h"}}},
+		{"/ignore SOL", "", "ignore", map[string][]string{"symbol": {"SOL"}}},
+		{"/ignore", "EOS up 5%", "ignore", map[string][]string{"symbol": {"EOS"}}},
+		{"/top", "", "top", map[string][]string{}},
+		{"/sub", "", "sub", nil},
+		{"/sub add", "", "sub add", map[string][]string{}},
+		{"/sub add UP  SOL\tAVAX", "", "sub add", map[string][]string{"target": {"UP", "SOL", "AVAX"}}},
+		{"/sub set up 1", "", "sub set", map[string][]string{"key": {"up"}, "value": {"1"}}},
+		{"/template UP <b>#{{.Channel}}</b>  {{rate .Rate}}", "", "template", map[string][]string{"channel": {"UP"}, "template": {"<b>#{{.Channel}}</b>  {{rate .Rate}}"}}},
+	}
+
+	tr := newTestRouter()
+	for _, tt := range tests {
+		if reply := tr.run(t, tt.line, tt.reply); reply != "" {
+			t.Errorf("%s: replied %q", tt.line, reply)
+			continue
+		}
+		if tr.ran != tt.ran || tt.values != nil && !reflect.DeepEqual(tr.values, tt.values) {
+			t.Errorf("%s: ran %q with %v, want %q with %v", tt.line, tr.ran, tr.values, tt.ran, tt.values)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		line  string
+		reply string // prefix, the usage follows
+	}{
+		{"/set", "missing key"},
+		{"/set up", "missing value"},
+		{"/set left 1", `invalid key "left": one of up, down`},
+		{"/set up two", `invalid value "two": not a number`},
+		{"/set up NaN", `invalid value "NaN": not a number`},
+		{"/set up -inf", `invalid value "-inf": not a number`},
+		{"/set up 1 2", "too many arguments"},
+		{"/ignore", "missing symbol"},
+		{"/ignore SOL soon", `invalid duration "soon": not a duration, e.g. 30m`},
+		{"/top 2.5", `invalid count "2.5": not an integer`},
+		{"/top -2", `invalid count "-2": must be positive`},
+		{"/sub remove", `unknown subcommand "remove"`},
+		{"/template", "missing channel"},
+	}
+
+	tr := newTestRouter()
+	for _, tt := range tests {
+		reply := tr.run(t, tt.line, "")
+		if tr.ran != "" {
+			t.Errorf("%s: ran %s", tt.line, tr.ran)
+		}
+		if !strings.HasPrefix(reply, escape(tt.reply)) || !strings.Contains(reply, "Usage:") {
+			t.Errorf("%s: replied %q, want %q and the usage", tt.line, reply, tt.reply)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		err   bool
+	}{
+		{"0", 0, false},
+		{"30m", 30 * time.Minute, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"30", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDuration(tt.value)
+		if got != tt.want || (err != nil) != tt.err {
+			t.Errorf("parseDuration(%q) = %s, %v, want %s, error %v", tt.value, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestHelp(t *testing.T) {
+	tr := newTestRouter()
+
+	if help := tr.Help("sub"); !strings.Contains(help, "/sub add [target...]") || !strings.Contains(help, "/sub set &lt;key&gt; &lt;value&gt;") {
+		t.Errorf("help of /sub = %q, want the usage of its subcommands", help)
+	}
+	if help := tr.Help("/s"); !strings.Contains(help, "/set &lt;up|down&gt; &lt;value&gt;") {
+		t.Errorf("help of /s = %q, want the usage of /set", help)
+	}
+	if help := tr.Help("nope"); help != "/nope not found" {
+		t.Errorf("help of an unknown command = %q", help)
+	}
+}
@@ -0,0 +1,154 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"alertbot/chat"
+)
+
+// Router of the commands
+type Router struct {
+	commands []*Command
+	byName   map[string]*Command
+	reply    func(to string, s string)
+	photo    func(to string, caption string, photo []byte)
+
+	Access *Access // allow-list, everyone is allowed without it
+}
+
+// NewRouter replying to the chat of a command, with /help registered
+func NewRouter(reply func(to string, s string), photo func(to string, caption string, photo []byte)) *Router {
+	r := &Router{byName: make(map[string]*Command), reply: reply, photo: photo}
+	r.Add(&Command{
+		Names:    []string{"/help"},
+		Summary:  "List the commands or show the usage of one",
+		Args:     []Arg{{Name: "command", Optional: true}},
+		Examples: []string{"/help set"},
+		Run:      func(c *Context) { c.Reply(r.Help(c.String("command"))) },
+	})
+
+	return r
+}
+
+// Add a command, its names must be unique
+func (r *Router) Add(cmd *Command) {
+	for _, name := range cmd.Names {
+		if _, found := r.byName[name]; found {
+			panic(fmt.Sprintf("command %s already added", name))
+		}
+		r.byName[name] = cmd
+	}
+	r.commands = append(r.commands, cmd)
+}
+
+// Commands in order of addition
+func (r *Router) Commands() []*Command {
+	return r.commands
+}
+
+// Run cmd for a request, parse errors are replied with the usage,
+// an error is returned when the sender is not allowed to run it
+func (r *Router) Run(cmd *Command, req chat.Request) error {
+	c := &Context{Request: req, router: r}
+
+	target, payload := cmd, req.Payload
+	if len(cmd.Subcommands) > 0 {
+		name, rest, _ := strings.Cut(strings.TrimSpace(payload), " ")
+		if sub := subcommand(cmd, strings.ToLower(name)); sub != nil {
+			target, payload = sub, rest
+		} else if name != "" || cmd.Run == nil {
+			if err := r.authorize(cmd, cmd, req); err != nil {
+				return err
+			}
+			c.command = cmd
+			c.Reply(escape((&ParseError{Command: cmd, Arg: "subcommand", Value: name}).Error()))
+			return nil
+		}
+	}
+
+	if err := r.authorize(cmd, target, req); err != nil {
+		return err
+	}
+
+	err := c.parse(target, payload)
+	c.command = cmd // usage of every subcommand
+	if err != nil {
+		if e, ok := err.(*ParseError); ok {
+			e.Command = cmd
+		}
+		c.Reply(escape(err.Error()))
+		return nil
+	}
+
+	target.Run(c)
+	return nil
+}
+
+// authorize the sender to run target of cmd, an attempt without the role is logged
+// and replied to known senders
+func (r *Router) authorize(cmd *Command, target *Command, req chat.Request) error {
+	if r.Access == nil {
+		return nil
+	}
+
+	required := Viewer
+	for _, role := range []Role{cmd.Role, target.Role} {
+		if role > required {
+			required = role
+		}
+	}
+
+	role := r.Access.Role(req.User, req.From)
+	if role >= required {
+		return nil
+	}
+
+	log.Printf("Unauthorized %s %s from %s in %s, %s required\n", cmd.Names[0], req.Payload, req.User, req.From, required)
+	err := fmt.Errorf("%s required", required)
+	if role > None {
+		r.reply(req.From, err.Error())
+	}
+	return err
+}
+
+func subcommand(cmd *Command, name string) *Command {
+	for _, sub := range cmd.Subcommands {
+		for _, n := range sub.Names {
+			if n == name {
+				return sub
+			}
+		}
+	}
+	return nil
+}
+
+// Help of a command, the list of commands if name is empty
+func (r *Router) Help(name string) string {
+	if name == "" {
+		lines := []string{}
+		for _, cmd := range r.commands {
+			lines = append(lines, fmt.Sprintf("%s - %s", strings.Join(cmd.Names, " "), cmd.Summary))
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+	cmd, found := r.byName[strings.ToLower(name)]
+	if !found {
+		return fmt.Sprintf("%s not found", escape(name))
+	}
+
+	ret := fmt.Sprintf("<b>%s</b> %s\n%s", strings.Join(cmd.Names, " "), cmd.Summary, escape(cmd.Usage()))
+	for _, sub := range cmd.Subcommands {
+		ret += fmt.Sprintf("\n  %s: %s", sub.Names[0], sub.Summary)
+	}
+	if len(cmd.Examples) > 0 {
+		ret += "\nExamples:\n" + escape(strings.Join(cmd.Examples, "\n"))
+	}
+
+	return ret
+}
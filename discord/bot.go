@@ -0,0 +1,175 @@
+package discordbot
+
+import (
+	"bytes"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"alertbot/chat"
+)
+
+// DiscordBot for discord based control
+type DiscordBot struct {
+	session   *discordgo.Session
+	channelID string
+	guildID   string
+	handlers  map[string]func(chat.Request) error
+	commands  []*discordgo.ApplicationCommand
+}
+
+// New create DiscordBot posting to channelID, slash commands are registered
+// in guildID or globally if it is empty
+func New(token string, channelID string, guildID string) *DiscordBot {
+	session, err := discordgo.New("Bot " + token)
+	if err != nil {
+		log.Fatal(err)
+		return nil
+	}
+
+	return &DiscordBot{
+		session:   session,
+		channelID: channelID,
+		guildID:   guildID,
+		handlers:  make(map[string]func(chat.Request) error),
+	}
+}
+
+// Start listen events
+func (db *DiscordBot) Start() {
+	db.RegisterCommand("/working", func(r chat.Request) error {
+		db.PostMessage("Yes!")
+		return nil
+	})
+
+	db.session.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		if i.Type != discordgo.InteractionApplicationCommand {
+			return
+		}
+
+		data := i.ApplicationCommandData()
+		handler, found := db.handlers["/"+data.Name]
+		if !found {
+			return
+		}
+
+		content := ""
+		if len(data.Options) > 0 {
+			content = data.Options[0].StringValue()
+		}
+
+		if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: strings.TrimSpace("/" + data.Name + " " + content),
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		}); err != nil {
+			log.Printf("Failed to respond to /%s: %v\n", data.Name, err)
+		}
+
+		r := chat.Request{Payload: content, From: i.ChannelID}
+		if i.Member != nil && i.Member.User != nil {
+			r.User = i.Member.User.ID
+		} else if i.User != nil {
+			r.User = i.User.ID
+		}
+		handler(r)
+	})
+
+	if err := db.session.Open(); err != nil {
+		log.Println(err)
+		return
+	}
+
+	if _, err := db.session.ApplicationCommandBulkOverwrite(db.session.State.User.ID, db.guildID, db.commands); err != nil {
+		log.Printf("Failed to register slash commands: %v\n", err)
+	}
+
+	select {}
+}
+
+// RegisterCommand for a slash command
+func (db *DiscordBot) RegisterCommand(command string, handler func(chat.Request) error) {
+	if _, found := db.handlers[command]; found {
+		log.Printf("%s command already registered\n", command)
+		return
+	}
+
+	db.handlers[command] = handler
+	db.commands = append(db.commands, &discordgo.ApplicationCommand{
+		Name:        strings.TrimPrefix(command, "/"),
+		Description: command,
+		Options: []*discordgo.ApplicationCommandOption{{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "args",
+			Description: "arguments",
+		}},
+	})
+}
+
+// RegisterCommands for slash commands
+func (db *DiscordBot) RegisterCommands(commands []string, handler func(chat.Request) error) {
+	for _, command := range commands {
+		db.RegisterCommand(command, handler)
+	}
+}
+
+// Describe a slash command, registered on start
+func (db *DiscordBot) Describe(command string, description string) {
+	for _, c := range db.commands {
+		if c.Name == strings.TrimPrefix(command, "/") {
+			c.Description = description
+		}
+	}
+}
+
+// PostMessage for message sending
+func (db *DiscordBot) PostMessage(message string) {
+	db.PostMessageTo("", message)
+}
+
+// PostMessageTo for message sending to a channel, the configured one if channelID is empty
+func (db *DiscordBot) PostMessageTo(channelID string, message string) {
+	if _, err := db.session.ChannelMessageSend(db.channel(channelID), message); err != nil {
+		log.Printf("Failed to send message on channel %s: %v\n", db.channel(channelID), err)
+	}
+}
+
+// PostMessageSilently for message sending without push and desktop notifications
+func (db *DiscordBot) PostMessageSilently(channelID string, message string) {
+	if _, err := db.session.ChannelMessageSendComplex(db.channel(channelID), &discordgo.MessageSend{
+		Content: message,
+		Flags:   discordgo.MessageFlagsSuppressNotifications,
+	}); err != nil {
+		log.Printf("Failed to send message on channel %s: %v\n", db.channel(channelID), err)
+	}
+}
+
+// PostPhotoTo for PNG image sending with a caption to a channel, the configured one if channelID is empty
+func (db *DiscordBot) PostPhotoTo(channelID string, caption string, photo []byte) {
+	db.postPhoto(channelID, caption, photo, 0)
+}
+
+// PostPhotoSilently for PNG image sending without push and desktop notifications
+func (db *DiscordBot) PostPhotoSilently(channelID string, caption string, photo []byte) {
+	db.postPhoto(channelID, caption, photo, discordgo.MessageFlagsSuppressNotifications)
+}
+
+func (db *DiscordBot) postPhoto(channelID string, caption string, photo []byte, flags discordgo.MessageFlags) {
+	if _, err := db.session.ChannelMessageSendComplex(db.channel(channelID), &discordgo.MessageSend{
+		Content: caption,
+		Files:   []*discordgo.File{{Name: "chart.png", ContentType: "image/png", Reader: bytes.NewReader(photo)}},
+		Flags:   flags,
+	}); err != nil {
+		log.Printf("Failed to send photo on channel %s: %v\n", db.channel(channelID), err)
+	}
+}
+
+func (db *DiscordBot) channel(channelID string) string {
+	if channelID == "" {
+		return db.channelID
+	}
+	return channelID
+}
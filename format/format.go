@@ -0,0 +1,93 @@
+// Package format renders alerts and messages for each destination.
+// Alerts and messages are written in the HTML subset of Telegram and converted
+// to the markup of the destination.
+package format
+
+import (
+	"encoding/json"
+	"html"
+	"regexp"
+	"strings"
+
+	"alertbot/record"
+)
+
+// Format of a destination
+type Format string
+
+const (
+	HTML     Format = "html"     // Telegram
+	Markdown Format = "markdown" // Discord
+	Mrkdwn   Format = "mrkdwn"   // Slack
+	Text     Format = "text"     // log file
+	JSON     Format = "json"
+)
+
+// Parse a format name, plain text if unknown
+func Parse(name string) Format {
+	switch f := Format(strings.ToLower(name)); f {
+	case HTML, Markdown, Mrkdwn, JSON:
+		return f
+	}
+
+	return Text
+}
+
+var (
+	htmlTag          = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	markdownReplacer = strings.NewReplacer("<b>", "**", "</b>", "**", "<u>", "__", "</u>", "__",
+		"<i>", "*", "</i>", "*", "<code>", "`", "</code>", "`")
+	mrkdwnReplacer = strings.NewReplacer("<b>", "*", "</b>", "*", "<u>", "_", "</u>", "_",
+		"<i>", "_", "</i>", "_", "<code>", "`", "</code>", "`")
+	mrkdwnEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+// Convert HTML into the markup of f, JSON is plain text
+func Convert(f Format, s string) string {
+	switch f {
+	case HTML:
+		return s
+	case Markdown:
+		return html.UnescapeString(htmlTag.ReplaceAllString(markdownReplacer.Replace(s), ""))
+	case Mrkdwn:
+		return mrkdwnEscaper.Replace(html.UnescapeString(htmlTag.ReplaceAllString(mrkdwnReplacer.Replace(s), "")))
+	}
+
+	return html.UnescapeString(htmlTag.ReplaceAllString(s, ""))
+}
+
+// Message is an alert or a plain message of a channel
+type Message struct {
+	Channel string
+	Alert   *record.Alert
+	Silent  bool // delivered without notification
+
+	html string
+}
+
+// NewMessage of channel written in HTML
+func NewMessage(channel string, s string) Message {
+	return Message{Channel: channel, html: s}
+}
+
+// Render the message for f
+func (m Message) Render(f Format) string {
+	if f != JSON {
+		return Convert(f, m.html)
+	}
+
+	v := struct {
+		Channel string        `json:"channel"`
+		Alert   *record.Alert `json:"alert,omitempty"`
+		Text    string        `json:"text"`
+	}{m.Channel, m.Alert, Convert(Text, m.html)}
+
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return Convert(Text, m.html)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,151 @@
+package format
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+
+	"alertbot/record"
+)
+
+// Templates render alerts in HTML by channel, a channel without custom template uses its default one.
+// Templates are executed with the record.Alert and the functions:
+// base (symbol without quote), rate, price, amount, round and time (of the alert, local).
+type Templates struct {
+	mu       sync.RWMutex
+	defaults map[string]*source
+	custom   map[string]*source
+	funcs    template.FuncMap
+}
+
+type source struct {
+	text     string
+	template *template.Template
+}
+
+// NewTemplates with default templates by channel, they must parse, base names a symbol in them
+func NewTemplates(defaults map[string]string, location *time.Location, base func(symbol string) string) *Templates {
+	printer := message.NewPrinter(language.English)
+	t := &Templates{
+		defaults: make(map[string]*source),
+		custom:   make(map[string]*source),
+		funcs: template.FuncMap{
+			"base":   base,
+			"rate":   func(v float64) string { return fmt.Sprintf("%4.2f", v) },
+			"price":  func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) },
+			"amount": func(v float64) string { return printer.Sprintf("%d", int64(v)) },
+			"round":  func(v float64) int { return int(v + 0.5) },
+			"time": func(t int64) string {
+				return time.UnixMilli(t).In(location).Format("15:04:05 2006-01-02")
+			},
+		},
+	}
+
+	for channel, text := range defaults {
+		t.defaults[channel] = &source{text: text, template: template.Must(t.parse(channel, text))}
+	}
+
+	return t
+}
+
+func (t *Templates) parse(channel string, text string) (*template.Template, error) {
+	return template.New(channel).Funcs(t.funcs).Parse(text)
+}
+
+// Set the custom template of channel, an empty text restores the default one
+func (t *Templates) Set(channel string, text string) error {
+	if text == "" {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		delete(t.custom, channel)
+		return nil
+	}
+
+	tmpl, err := t.parse(channel, text)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(new(strings.Builder), &record.Alert{Channel: channel, Symbol: "BTCUSDT"}); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.custom[channel] = &source{text: text, template: tmpl}
+	return nil
+}
+
+// Custom templates by channel
+func (t *Templates) Custom() map[string]string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	ret := make(map[string]string, len(t.custom))
+	for channel, s := range t.custom {
+		ret[channel] = s.text
+	}
+
+	return ret
+}
+
+// Alert message, rendered with the default template if the custom one fails
+func (t *Templates) Alert(a *record.Alert) Message {
+	t.mu.RLock()
+	sources := []*source{t.custom[a.Channel], t.defaults[a.Channel]}
+	t.mu.RUnlock()
+
+	for _, s := range sources {
+		if s == nil {
+			continue
+		}
+
+		var b strings.Builder
+		if err := s.template.Execute(&b, a); err != nil {
+			log.Println(err)
+			continue
+		}
+
+		return Message{Channel: a.Channel, Alert: a, html: b.String()}
+	}
+
+	return Message{Channel: a.Channel, Alert: a, html: fmt.Sprintf("<b>#%s %s</b> P: <u>%s</u>",
+		a.Channel, a.Symbol, strconv.FormatFloat(a.Price, 'f', -1, 64))}
+}
+
+func (t *Templates) String() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	channels := make([]string, 0, len(t.defaults)+len(t.custom))
+	for channel := range t.defaults {
+		channels = append(channels, channel)
+	}
+	for channel := range t.custom {
+		if _, found := t.defaults[channel]; !found {
+			channels = append(channels, channel)
+		}
+	}
+	sort.Strings(channels)
+
+	lines := []string{}
+	for _, channel := range channels {
+		kind, s := "custom", t.custom[channel]
+		if s == nil {
+			kind, s = "default", t.defaults[channel]
+		}
+		lines = append(lines, fmt.Sprintf("%s (%s): <code>%s</code>", channel, kind, html.EscapeString(s.text)))
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -4,9 +4,13 @@ go 1.21.5
 
 require (
 	github.com/adshao/go-binance/v2 v2.4.5
+	github.com/bwmarrin/discordgo v0.28.1
 	github.com/joho/godotenv v1.5.1
 	github.com/slack-go/slack v0.12.3
+	go.etcd.io/bbolt v1.3.10
+	go.uber.org/atomic v1.11.0
 	golang.org/x/text v0.14.0
+	gopkg.in/telebot.v3 v3.2.1
 )
 
 require (
@@ -19,7 +23,7 @@ require (
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	go.uber.org/atomic v1.11.0 // indirect
-	gopkg.in/telebot.v3 v3.2.1 // indirect
+	golang.org/x/crypto v0.0.0-20220411220226-7b82a4e95df4 // indirect
+	golang.org/x/sys v0.5.0 // indirect
 	gopkg.in/tucnak/telebot.v1 v1.0.0-20170912115553-00cebf376d79 // indirect
 )
@@ -9,6 +9,9 @@ import (
 	"github.com/joho/godotenv"
 
 	binancefilter "alertbot/binance"
+	discordbot "alertbot/discord"
+	"alertbot/format"
+	slackbot "alertbot/slack"
 	telegrambot "alertbot/telegram"
 )
 
@@ -19,28 +22,26 @@ func main() {
 	log.SetFlags(0)
 	log.SetOutput(new(logWriter))
 
-	messenger := telegrambot.New(os.Getenv("TELEGRAM_USERID"), os.Getenv("TELEGRAM_TOKEN"))
-	filter := binancefilter.New(func(message string) {
-		messenger.PostMessage(message)
-	}, os.Getenv("LOCATION_TIME"))
-
-	messenger.RegisterCommands([]string{"/update"}, func(content string) { filter.UpdateData(content) })
-	messenger.RegisterCommands([]string{"/set", "/s"}, func(content string) { filter.UpdateConfiguration(content) })
-	messenger.RegisterCommands([]string{"/get", "/g"}, func(content string) { filter.GetConfiguration(content) })
-	messenger.RegisterCommands([]string{"/mute"}, func(content string) { filter.Mute(content) })
-	messenger.RegisterCommands([]string{"/unmute"}, func(content string) { filter.Unmute(content) })
-	messenger.RegisterCommands([]string{"/restart"}, func(content string) { filter.Restart(content) })
-	messenger.RegisterCommands([]string{"/filter"}, func(content string) { filter.Filter(content) })
-	messenger.RegisterCommands([]string{"/clear"}, func(content string) { filter.Clear(content) })
-	messenger.RegisterCommands([]string{"/ignore"}, func(content string) { filter.Ignore(content) })
-	messenger.RegisterCommands([]string{"/unignore"}, func(content string) { filter.Unignore(content) })
-	messenger.RegisterCommands([]string{"/price", "/p"}, func(content string) { filter.Price(content) })
-	messenger.RegisterCommands([]string{"/fr", "/f"}, func(content string) { filter.FundingRate(content) })
-	messenger.RegisterCommands([]string{"/frtop", "/ft"}, func(content string) { filter.FundingRateTop(content) })
-	messenger.RegisterCommands([]string{"/frbot", "/fb"}, func(content string) { filter.FundingRateBottom(content) })
+	messenger := messengers{defaultMessenger: {telegrambot.New(os.Getenv("TELEGRAM_USERID"), os.Getenv("TELEGRAM_TOKEN")), format.HTML}}
+	defaultChats := []string{defaultMessenger + ":" + os.Getenv("TELEGRAM_USERID")}
+	if os.Getenv("DISCORD_TOKEN") != "" {
+		messenger["discord"] = destination{discordbot.New(os.Getenv("DISCORD_TOKEN"), os.Getenv("DISCORD_CHANNEL_ID"), os.Getenv("DISCORD_GUILD_ID")), format.Markdown}
+		defaultChats = append(defaultChats, "discord:"+os.Getenv("DISCORD_CHANNEL_ID"))
+	}
+	if os.Getenv("SLACK_AUTH_TOKEN") != "" {
+		messenger["slack"] = destination{slackbot.New(os.Getenv("SLACK_AUTH_TOKEN"), os.Getenv("SLACK_APP_TOKEN"), os.Getenv("SLACK_ALERT_BINANCE_CHANNEL_ID")), format.Mrkdwn}
+		defaultChats = append(defaultChats, "slack:"+os.Getenv("SLACK_ALERT_BINANCE_CHANNEL_ID"))
+	}
+	filter := binancefilter.New(messenger.PostMessageTo, messenger.PostPhotoTo, os.Getenv("LOCATION_TIME"))
+	filter.DefaultChats(defaultChats...)
+	router := filter.Router()
+	if err := router.Access.Seed(defaultMessenger + ":" + os.Getenv("TELEGRAM_USERID") + "=admin"); err != nil {
+		log.Println(err)
+	}
+	messenger.Route(router)
 
-	go func() { messenger.Start() }()
-	messenger.PostMessage(fmt.Sprintf("Started %s", time.Now().In(loc).Format("2006-01-02 15:04:05 MST")))
+	messenger.Start()
+	messenger.PostMessageTo("", format.NewMessage(binancefilter.SYSTEM, fmt.Sprintf("Started %s", time.Now().In(loc).Format("2006-01-02 15:04:05 MST"))))
 	filter.Start()
 }
 
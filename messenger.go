@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+
+	"alertbot/chat"
+	"alertbot/command"
+	"alertbot/format"
+	"alertbot/record"
+	telegrambot "alertbot/telegram"
+)
+
+const defaultMessenger = "telegram"
+
+type messenger interface {
+	Start()
+	RegisterCommands(commands []string, handler func(chat.Request) error)
+	PostMessageTo(chatID string, message string)
+	PostPhotoTo(chatID string, caption string, photo []byte)
+}
+
+// buttonMessenger attaches inline buttons to alerts, sent silently below the priority
+type buttonMessenger interface {
+	PostMessageWithButtons(chatID string, message string, buttons []telegrambot.Button, silent bool)
+	PostPhotoWithButtons(chatID string, caption string, photo []byte, buttons []telegrambot.Button, silent bool)
+}
+
+// silentMessenger sends without notification
+type silentMessenger interface {
+	PostMessageSilently(chatID string, message string)
+	PostPhotoSilently(chatID string, caption string, photo []byte)
+}
+
+// alertButtons to ignore or snooze the symbol of an alert, mute its channel or chart it
+func alertButtons(a *record.Alert) []telegrambot.Button {
+	return []telegrambot.Button{
+		{Text: "Ignore " + a.Base, Command: "/ignore", Payload: a.Symbol},
+		{Text: "Snooze 1h", Command: "/snooze", Payload: a.Symbol + " 1h"},
+		{Text: "Mute " + a.Channel, Command: "/mute", Payload: a.Channel},
+		{Text: "Chart", Command: "/chart", Payload: a.Symbol},
+	}
+}
+
+// destination is a messenger and the format of its messages
+type destination struct {
+	messenger
+	format format.Format
+}
+
+// messengers fan out to every configured messenger by name, a destination
+// "discord:<id>" goes to one messenger only, an unprefixed one to the default messenger
+type messengers map[string]destination
+
+func (ms messengers) Start() {
+	for _, m := range ms {
+		go m.Start()
+	}
+}
+
+// describer shows the commands in the menu of a messenger
+type describer interface {
+	Describe(command string, description string)
+}
+
+// Route the commands of every messenger, replies go back to the messenger and chat of the command
+func (ms messengers) Route(router *command.Router) {
+	for name, m := range ms {
+		for _, cmd := range router.Commands() {
+			name, cmd := name, cmd
+			m.RegisterCommands(cmd.Names, func(r chat.Request) error {
+				r.From, r.User = name+":"+r.From, name+":"+r.User
+				return router.Run(cmd, r)
+			})
+			if d, ok := m.messenger.(describer); ok {
+				d.Describe(cmd.Names[0], cmd.Summary)
+			}
+		}
+	}
+}
+
+func (ms messengers) PostMessageTo(to string, message format.Message) {
+	ms.each(to, func(m destination, chatID string) {
+		if bm, ok := m.messenger.(buttonMessenger); ok && message.Alert != nil {
+			bm.PostMessageWithButtons(chatID, message.Render(m.format), alertButtons(message.Alert), message.Silent)
+			return
+		}
+		if sm, ok := m.messenger.(silentMessenger); ok && message.Silent {
+			sm.PostMessageSilently(chatID, message.Render(m.format))
+			return
+		}
+		m.PostMessageTo(chatID, message.Render(m.format))
+	})
+}
+
+func (ms messengers) PostPhotoTo(to string, caption format.Message, photo []byte) {
+	ms.each(to, func(m destination, chatID string) {
+		if bm, ok := m.messenger.(buttonMessenger); ok && caption.Alert != nil {
+			bm.PostPhotoWithButtons(chatID, caption.Render(m.format), photo, alertButtons(caption.Alert), caption.Silent)
+			return
+		}
+		if sm, ok := m.messenger.(silentMessenger); ok && caption.Silent {
+			sm.PostPhotoSilently(chatID, caption.Render(m.format), photo)
+			return
+		}
+		m.PostPhotoTo(chatID, caption.Render(m.format), photo)
+	})
+}
+
+func (ms messengers) each(to string, fn func(destination, string)) {
+	if to == "" {
+		for _, m := range ms {
+			fn(m, "")
+		}
+		return
+	}
+
+	name, chatID, found := strings.Cut(to, ":")
+	if !found {
+		name, chatID = defaultMessenger, to
+	}
+
+	if m, found := ms[name]; found {
+		fn(m, chatID)
+	}
+}
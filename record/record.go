@@ -0,0 +1,151 @@
+// Package record stores structured alerts in an embedded bbolt database.
+package record
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var alertsBucket = []byte("alerts")
+
+// Alert is a structured alert
+type Alert struct {
+	ID         uint64
+	Channel    string
+	Symbol     string
+	Base       string `json:",omitempty"` // assets of the symbol, e.g. SOL and USDT
+	Quote      string `json:",omitempty"`
+	Market     string // S for spot, F for futures, C for COIN-M futures
+	Price      float64
+	SpotPrice  float64 `json:",omitempty"` // of the spot market when a futures trade alerted, the reference of its returns
+	Rate       float64
+	VolumeRate float64 `json:",omitempty"`
+	Value      float64
+	Quantity   float64 `json:",omitempty"`
+	Number     int     `json:",omitempty"` // UP/DOWN streak
+	Threshold  float64 // rate threshold of the channel when alerted
+	Priority   float64 `json:",omitempty"` // how far the alert exceeds its thresholds, e.g. 10 for 10x
+	Time       int64   // milliseconds
+
+	Returns map[string]float64 `json:",omitempty"` // forward returns in percent by horizon
+}
+
+// Query selects alerts in [From, To), To zero is now,
+// empty Channel or Symbol match any
+type Query struct {
+	From    int64
+	To      int64
+	Channel string
+	Symbol  string
+}
+
+func (q Query) match(a *Alert) bool {
+	return (q.Channel == "" || q.Channel == a.Channel) && (q.Symbol == "" || q.Symbol == a.Symbol)
+}
+
+// Store of alerts ordered by time
+type Store struct {
+	db *bolt.DB
+}
+
+// Open create or open the store at path
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(alertsBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close the store
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Add an alert, assigning its ID
+func (s *Store) Add(a *Alert) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(alertsBucket)
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		a.ID = id
+
+		return put(b, a)
+	})
+}
+
+// Update an alert previously added
+func (s *Store) Update(a *Alert) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx.Bucket(alertsBucket), a)
+	})
+}
+
+// Find the alerts matching q, oldest first
+func (s *Store) Find(q Query) ([]Alert, error) {
+	ret := []Alert{}
+	err := s.Each(q, func(a *Alert) error {
+		ret = append(ret, *a)
+		return nil
+	})
+
+	return ret, err
+}
+
+// Each call fn for the alerts matching q, oldest first
+func (s *Store) Each(q Query, fn func(*Alert) error) error {
+	to := q.To
+	if to == 0 {
+		to = time.Now().UnixMilli()
+	}
+
+	return s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(alertsBucket).Cursor()
+		for k, v := c.Seek(key(q.From, 0)); k != nil && int64(binary.BigEndian.Uint64(k)) < to; k, v = c.Next() {
+			var a Alert
+			if err := json.Unmarshal(v, &a); err != nil {
+				return err
+			}
+
+			if !q.match(&a) {
+				continue
+			}
+
+			if err := fn(&a); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func put(b *bolt.Bucket, a *Alert) error {
+	v, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	return b.Put(key(a.Time, a.ID), v)
+}
+
+func key(t int64, id uint64) []byte {
+	k := make([]byte, 16)
+	binary.BigEndian.PutUint64(k, uint64(t))
+	binary.BigEndian.PutUint64(k[8:], id)
+	return k
+}
@@ -1,29 +1,40 @@
 package slackbot
 
 import (
+	"bytes"
 	"context"
 	"log"
+	"strings"
 
 	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
 	"github.com/slack-go/slack/socketmode"
+
+	"alertbot/chat"
 )
 
+// threadPrefix starts a command replied in the thread of a message, e.g. "!ignore",
+// slash commands do not carry their thread
+const threadPrefix = "!"
+
 // SlackBot for slack based control
 type SlackBot struct {
 	client       *slack.Client
 	socketClient *socketmode.Client
 	context      context.Context
-	handlers     map[string]func(string)
+	channelID    string
+	handlers     map[string]func(chat.Request) error
 }
 
-// New create SlackBot
-func New(token string, appToken string) *SlackBot {
+// New create SlackBot posting to channelID by default
+func New(token string, appToken string, channelID string) *SlackBot {
 	_client := slack.New(token, slack.OptionDebug(false), slack.OptionAppLevelToken(appToken))
 	return &SlackBot{
 		client:       _client,
 		socketClient: socketmode.New(_client, socketmode.OptionDebug(false)),
 		context:      context.Background(),
-		handlers:     make(map[string]func(string)),
+		channelID:    channelID,
+		handlers:     make(map[string]func(chat.Request) error),
 	}
 }
 
@@ -44,7 +55,16 @@ func (sb *SlackBot) Start() {
 						continue
 					}
 					socketClient.Ack(*event.Request)
-					sb.handleSlashCommand(command.Command, command.Text)
+					sb.handleSlashCommand(command.Command, command.Text, command.ChannelID, command.UserID)
+				case socketmode.EventTypeEventsAPI:
+					eventsAPIEvent, ok := event.Data.(slackevents.EventsAPIEvent)
+					if !ok {
+						continue
+					}
+					socketClient.Ack(*event.Request)
+					if message, ok := eventsAPIEvent.InnerEvent.Data.(*slackevents.MessageEvent); ok {
+						sb.handleThreadCommand(message)
+					}
 				}
 			}
 
@@ -55,7 +75,7 @@ func (sb *SlackBot) Start() {
 }
 
 // RegisterHandler for a slash command
-func (sb *SlackBot) RegisterHandler(command string, handler func(string)) {
+func (sb *SlackBot) RegisterHandler(command string, handler func(chat.Request) error) {
 	if _, found := sb.handlers[command]; found {
 		log.Printf("%s command already registered\n", command)
 		return
@@ -64,17 +84,77 @@ func (sb *SlackBot) RegisterHandler(command string, handler func(string)) {
 	sb.handlers[command] = handler
 }
 
-// PostMessage for message sending
-func (sb *SlackBot) PostMessage(channelID string, message string) {
-	if _, _, err := sb.client.PostMessage(channelID, slack.MsgOptionText(message, false)); err != nil {
-		log.Printf("Failed to send message on channel %s\n", channelID)
+// RegisterCommands for slash commands
+func (sb *SlackBot) RegisterCommands(commands []string, handler func(chat.Request) error) {
+	for _, command := range commands {
+		sb.RegisterHandler(command, handler)
+	}
+}
+
+// PostMessage for message sending on the default channel
+func (sb *SlackBot) PostMessage(message string) {
+	sb.PostMessageTo("", message)
+}
+
+// PostMessageTo for mrkdwn message sending as a section block, on the default channel if channelID is empty
+func (sb *SlackBot) PostMessageTo(channelID string, message string) {
+	section := slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, message, false, false), nil, nil)
+	if _, _, err := sb.client.PostMessage(sb.channel(channelID), slack.MsgOptionText(message, false), slack.MsgOptionBlocks(section)); err != nil {
+		log.Printf("Failed to send message on channel %s\n", sb.channel(channelID))
+	}
+}
+
+// PostPhotoTo for PNG image uploading with a caption, on the default channel if channelID is empty
+func (sb *SlackBot) PostPhotoTo(channelID string, caption string, photo []byte) {
+	if _, err := sb.client.UploadFileV2(slack.UploadFileV2Parameters{
+		Reader:         bytes.NewReader(photo),
+		FileSize:       len(photo),
+		Filename:       "chart.png",
+		InitialComment: caption,
+		Channel:        sb.channel(channelID),
+	}); err != nil {
+		log.Printf("Failed to upload photo on channel %s\n", sb.channel(channelID))
+	}
+}
+
+func (sb *SlackBot) channel(channelID string) string {
+	if channelID == "" {
+		return sb.channelID
 	}
+	return channelID
 }
 
-func (sb *SlackBot) handleSlashCommand(command string, content string) {
+func (sb *SlackBot) handleSlashCommand(command string, content string, channelID string, userID string) {
 	if _, found := sb.handlers[command]; !found {
 		return
 	}
 
-	sb.handlers[command](content)
+	sb.handlers[command](chat.Request{Payload: content, From: channelID, User: userID})
+}
+
+// handleThreadCommand run a command replied in a thread with the text of the thread parent
+func (sb *SlackBot) handleThreadCommand(message *slackevents.MessageEvent) {
+	if message.BotID != "" || message.ThreadTimeStamp == "" || !strings.HasPrefix(message.Text, threadPrefix) {
+		return
+	}
+
+	command, payload, _ := strings.Cut(strings.TrimPrefix(message.Text, threadPrefix), " ")
+	handler, found := sb.handlers["/"+command]
+	if !found {
+		return
+	}
+
+	r := chat.Request{Payload: strings.TrimSpace(payload), From: message.Channel, User: message.User}
+	msgs, _, _, err := sb.client.GetConversationReplies(&slack.GetConversationRepliesParameters{
+		ChannelID: message.Channel,
+		Timestamp: message.ThreadTimeStamp,
+		Limit:     1,
+	})
+	if err != nil {
+		log.Printf("Failed to get thread %s: %v\n", message.ThreadTimeStamp, err)
+	} else if len(msgs) > 0 {
+		r.Reply = msgs[0].Text
+	}
+
+	handler(r)
 }
@@ -1,19 +1,34 @@
 package telegrambot
 
 import (
+	"bytes"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 
 	tele "gopkg.in/telebot.v3"
+
+	"alertbot/chat"
 )
 
+// commandButton is the unique of inline buttons running a command
+const commandButton = "cmd"
+
+// Button of an inline keyboard running a registered command with its payload
+type Button struct {
+	Text    string
+	Command string
+	Payload string
+}
+
 // TelegramBot for slack based control
 type TelegramBot struct {
 	user        *tele.User
 	bot         *tele.Bot
 	sendOptions *tele.SendOptions
-	handlers    map[string]func(string)
+	handlers    map[string]func(chat.Request) error
+	menu        []tele.Command
 }
 
 // New create TelegramBot
@@ -39,40 +54,184 @@ func New(userID string, token string) *TelegramBot {
 		user:        &tele.User{ID: _userID},
 		bot:         b,
 		sendOptions: &tele.SendOptions{ParseMode: tele.ModeHTML, DisableWebPagePreview: true},
-		handlers:    make(map[string]func(string)),
+		handlers:    make(map[string]func(chat.Request) error),
 	}
 }
 
 // Start listen events
 func (tb *TelegramBot) Start() {
-	tb.RegisterCommand("/working", func(s string) { tb.PostMessage("Yes!") })
+	tb.RegisterCommand("/working", func(r chat.Request) error {
+		tb.PostMessage("Yes!")
+		return nil
+	})
+	tb.bot.Handle("\f"+commandButton, tb.handleButton)
+
+	if len(tb.menu) > 0 {
+		if err := tb.bot.SetCommands(tb.menu); err != nil {
+			log.Printf("Failed to set the command menu: %v\n", err)
+		}
+	}
 
 	tb.bot.Start()
 }
 
-// RegisterCommand for a slash command
-func (tb *TelegramBot) RegisterCommand(command string, handler func(string)) {
+// handleButton run the command of a pressed button and confirm it by editing the message
+func (tb *TelegramBot) handleButton(c tele.Context) error {
+	command, payload, _ := strings.Cut(c.Callback().Data, " ")
+	handler, found := tb.handlers[command]
+	if !found {
+		return c.Respond(&tele.CallbackResponse{Text: "unknown command"})
+	}
+
+	if err := handler(chat.Request{Payload: payload, From: strconv.FormatInt(c.Chat().ID, 10), User: sender(c)}); err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: err.Error()})
+	}
+
+	msg := c.Message()
+	if msg == nil || msg.ReplyMarkup == nil {
+		return c.Respond()
+	}
+
+	// the pressed button is removed and its text confirmed
+	pressed, keyboard := "", [][]tele.InlineButton{}
+	for _, row := range msg.ReplyMarkup.InlineKeyboard {
+		kept := []tele.InlineButton{}
+		for _, button := range row {
+			if strings.TrimPrefix(button.Data, "\f"+commandButton+"|") == c.Callback().Data {
+				pressed = button.Text
+				continue
+			}
+			kept = append(kept, button)
+		}
+		if len(kept) > 0 {
+			keyboard = append(keyboard, kept)
+		}
+	}
+
+	confirmation := "\n✓ " + pressed
+	if c.Sender() != nil && c.Sender().Username != "" {
+		confirmation += " by @" + c.Sender().Username
+	}
+	options := &tele.SendOptions{Entities: msg.Entities, ReplyMarkup: &tele.ReplyMarkup{InlineKeyboard: keyboard}}
+
+	var err error
+	if msg.Photo != nil {
+		options.Entities = msg.CaptionEntities
+		_, err = tb.bot.EditCaption(msg, msg.Caption+confirmation, options)
+	} else {
+		_, err = tb.bot.Edit(msg, msg.Text+confirmation, options)
+	}
+	if err != nil {
+		log.Printf("Failed to confirm %s: %v\n", c.Callback().Data, err)
+	}
+
+	return c.Respond()
+}
+
+// RegisterCommand for a slash command, the request has the text of the message replied to
+func (tb *TelegramBot) RegisterCommand(command string, handler func(chat.Request) error) {
 	if _, found := tb.handlers[command]; found {
 		log.Printf("%s command already registered\n", command)
 		return
 	}
 
 	tb.bot.Handle(command, func(c tele.Context) error {
-		handler(c.Message().Payload)
+		r := chat.Request{Payload: c.Message().Payload, From: strconv.FormatInt(c.Chat().ID, 10), User: sender(c)}
+		if reply := c.Message().ReplyTo; reply != nil {
+			r.Reply = reply.Text
+			if r.Reply == "" {
+				r.Reply = reply.Caption
+			}
+		}
+		handler(r)
 		return nil
 	})
 
 	tb.handlers[command] = handler
 }
 
+func sender(c tele.Context) string {
+	if c.Sender() == nil {
+		return ""
+	}
+	return strconv.FormatInt(c.Sender().ID, 10)
+}
+
 // RegisterCommands for slash commands
-func (tb *TelegramBot) RegisterCommands(commands []string, handler func(string)) {
+func (tb *TelegramBot) RegisterCommands(commands []string, handler func(chat.Request) error) {
 	for _, command := range commands {
 		tb.RegisterCommand(command, handler)
 	}
 }
 
+// Describe a command in the menu, set on start
+func (tb *TelegramBot) Describe(command string, description string) {
+	tb.menu = append(tb.menu, tele.Command{Text: strings.TrimPrefix(command, "/"), Description: description})
+}
+
 // PostMessage for message sending
 func (tb *TelegramBot) PostMessage(message string) {
 	tb.bot.Send(tb.user, message, tb.sendOptions)
 }
+
+// PostMessageTo for message sending to a chat, the user if chatID is empty
+func (tb *TelegramBot) PostMessageTo(chatID string, message string) {
+	if _, err := tb.bot.Send(tb.recipient(chatID), message, tb.sendOptions); err != nil {
+		log.Printf("Failed to send message to %s: %v\n", chatID, err)
+	}
+}
+
+// PostPhoto for PNG image sending with a caption
+func (tb *TelegramBot) PostPhoto(caption string, photo []byte) {
+	tb.PostPhotoTo("", caption, photo)
+}
+
+// PostPhotoTo for PNG image sending with a caption to a chat, the user if chatID is empty
+func (tb *TelegramBot) PostPhotoTo(chatID string, caption string, photo []byte) {
+	if _, err := tb.bot.Send(tb.recipient(chatID), &tele.Photo{File: tele.FromReader(bytes.NewReader(photo)), Caption: caption}, tb.sendOptions); err != nil {
+		log.Printf("Failed to send photo to %s: %v\n", chatID, err)
+	}
+}
+
+// PostMessageWithButtons for message sending with a row of inline buttons, silently without notification sound
+func (tb *TelegramBot) PostMessageWithButtons(chatID string, message string, buttons []Button, silent bool) {
+	if _, err := tb.bot.Send(tb.recipient(chatID), message, tb.options(silent), keyboard(buttons)); err != nil {
+		log.Printf("Failed to send message to %s: %v\n", chatID, err)
+	}
+}
+
+// PostPhotoWithButtons for PNG image sending with a caption and an inline keyboard, silently without notification sound
+func (tb *TelegramBot) PostPhotoWithButtons(chatID string, caption string, photo []byte, buttons []Button, silent bool) {
+	if _, err := tb.bot.Send(tb.recipient(chatID), &tele.Photo{File: tele.FromReader(bytes.NewReader(photo)), Caption: caption}, tb.options(silent), keyboard(buttons)); err != nil {
+		log.Printf("Failed to send photo to %s: %v\n", chatID, err)
+	}
+}
+
+func (tb *TelegramBot) options(silent bool) *tele.SendOptions {
+	options := *tb.sendOptions
+	options.DisableNotification = silent
+	return &options
+}
+
+func keyboard(buttons []Button) *tele.ReplyMarkup {
+	row := []tele.InlineButton{}
+	for _, b := range buttons {
+		row = append(row, tele.InlineButton{Unique: commandButton, Text: b.Text, Data: strings.TrimSpace(b.Command + " " + b.Payload)})
+	}
+
+	return &tele.ReplyMarkup{InlineKeyboard: [][]tele.InlineButton{row}}
+}
+
+func (tb *TelegramBot) recipient(chatID string) tele.Recipient {
+	if chatID == "" {
+		return tb.user
+	}
+
+	id, err := strconv.ParseInt(chatID, 10, 64)
+	if err != nil {
+		log.Printf("Invalid chat %s\n", chatID)
+		return tb.user
+	}
+
+	return tele.ChatID(id)
+}
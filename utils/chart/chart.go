@@ -0,0 +1,168 @@
+// Package chart renders small price and volume charts as PNG images.
+package chart
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+)
+
+const (
+	width        = 800
+	height       = 400
+	padding      = 16
+	volumeHeight = 100
+	markerRadius = 6
+)
+
+var (
+	background = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	grid       = color.RGBA{0xe6, 0xe6, 0xe6, 0xff}
+	priceLine  = color.RGBA{0x1f, 0x77, 0xb4, 0xff}
+	volumeBar  = color.RGBA{0xa0, 0xa0, 0xa0, 0xff}
+	minMarker  = color.RGBA{0xd6, 0x27, 0x28, 0xff}
+	maxMarker  = color.RGBA{0x2c, 0xa0, 0x2c, 0xff}
+)
+
+// Point is a chart sample, Volume is the accumulated quote volume
+type Point struct {
+	Time   int64
+	Price  float64
+	Volume float64
+}
+
+// Render draws the price line and volume bars of points and marks the min and max points.
+// A negative minIndex or maxIndex is not marked.
+func Render(points []Point, minIndex int, maxIndex int) ([]byte, error) {
+	if len(points) < 2 {
+		return nil, errors.New("not enough points")
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{background}, image.Point{}, draw.Src)
+
+	priceTop, priceBottom := padding, height-volumeHeight-2*padding
+	volumeTop, volumeBottom := height-volumeHeight-padding, height-padding
+	for i := 0; i <= 4; i++ {
+		y := priceTop + i*(priceBottom-priceTop)/4
+		hline(img, padding, width-padding, y, grid)
+	}
+	hline(img, padding, width-padding, volumeBottom, grid)
+
+	lowPrice, highPrice := math.Inf(1), math.Inf(-1)
+	start, end := points[0].Time, points[len(points)-1].Time
+	highVolume := 0.0
+	for i, p := range points {
+		lowPrice = math.Min(lowPrice, p.Price)
+		highPrice = math.Max(highPrice, p.Price)
+		if i > 0 {
+			highVolume = math.Max(highVolume, p.Volume-points[i-1].Volume)
+		}
+	}
+	if highPrice == lowPrice {
+		highPrice, lowPrice = highPrice*1.001, lowPrice*0.999
+	}
+	if end == start {
+		end = start + 1
+	}
+
+	x := func(t int64) int {
+		return padding + int(float64(t-start)*float64(width-2*padding)/float64(end-start))
+	}
+	y := func(price float64) int {
+		return priceBottom - int((price-lowPrice)*float64(priceBottom-priceTop)/(highPrice-lowPrice))
+	}
+
+	if highVolume > 0 {
+		for i := 1; i < len(points); i++ {
+			delta := points[i].Volume - points[i-1].Volume
+			if delta <= 0 {
+				continue
+			}
+			top := volumeBottom - int(delta*float64(volumeBottom-volumeTop)/highVolume)
+			vline(img, x(points[i].Time), top, volumeBottom, volumeBar)
+		}
+	}
+
+	for i := 1; i < len(points); i++ {
+		line(img, x(points[i-1].Time), y(points[i-1].Price), x(points[i].Time), y(points[i].Price), priceLine)
+	}
+
+	if minIndex >= 0 && minIndex < len(points) {
+		circle(img, x(points[minIndex].Time), y(points[minIndex].Price), minMarker)
+	}
+	if maxIndex >= 0 && maxIndex < len(points) {
+		circle(img, x(points[maxIndex].Time), y(points[maxIndex].Price), maxMarker)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func hline(img *image.RGBA, x0 int, x1 int, y int, c color.Color) {
+	for x := x0; x <= x1; x++ {
+		img.Set(x, y, c)
+	}
+}
+
+func vline(img *image.RGBA, x int, y0 int, y1 int, c color.Color) {
+	for y := y0; y <= y1; y++ {
+		img.Set(x, y, c)
+	}
+}
+
+// line draws a two pixels wide line with Bresenham's algorithm
+func line(img *image.RGBA, x0 int, y0 int, x1 int, y1 int, c color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	err := dx + dy
+	for {
+		img.Set(x0, y0, c)
+		img.Set(x0, y0+1, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func circle(img *image.RGBA, cx int, cy int, c color.Color) {
+	for y := -markerRadius; y <= markerRadius; y++ {
+		for x := -markerRadius; x <= markerRadius; x++ {
+			if x*x+y*y <= markerRadius*markerRadius {
+				img.Set(cx+x, cy+y, c)
+			}
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,120 @@
+// Package window implements typed ring buffers and sliding window min/max.
+package window
+
+const initialCapacity = 64
+
+// Ring is a bounded FIFO buffer, pushing to a full ring evicts the oldest value.
+// Its storage grows on demand up to the capacity.
+// The zero value is not usable, create rings with NewRing.
+type Ring[T any] struct {
+	buf      []T
+	head     int
+	len      int
+	capacity int
+}
+
+// NewRing returns an empty ring holding at most capacity values.
+func NewRing[T any](capacity int) *Ring[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	size := capacity
+	if size > initialCapacity {
+		size = initialCapacity
+	}
+
+	return &Ring[T]{buf: make([]T, size), capacity: capacity}
+}
+
+// Len returns the number of values of ring r.
+func (r *Ring[T]) Len() int { return r.len }
+
+// Cap returns the capacity of ring r.
+func (r *Ring[T]) Cap() int { return r.capacity }
+
+// At returns the i-th value, 0 being the oldest one.
+func (r *Ring[T]) At(i int) T {
+	return r.buf[(r.head+i)%len(r.buf)]
+}
+
+// Front returns the oldest value or the zero value if r is empty.
+func (r *Ring[T]) Front() T {
+	if r.len == 0 {
+		var zero T
+		return zero
+	}
+	return r.buf[r.head]
+}
+
+// Back returns the newest value or the zero value if r is empty.
+func (r *Ring[T]) Back() T {
+	if r.len == 0 {
+		var zero T
+		return zero
+	}
+	return r.At(r.len - 1)
+}
+
+// PushBack appends v and returns the evicted oldest value if r was full.
+func (r *Ring[T]) PushBack(v T) (T, bool) {
+	var evicted T
+	if r.len == len(r.buf) && r.len < r.capacity {
+		r.grow()
+	}
+
+	full := r.len == len(r.buf)
+	if full {
+		evicted = r.PopFront()
+	}
+
+	r.buf[(r.head+r.len)%len(r.buf)] = v
+	r.len++
+
+	return evicted, full
+}
+
+// PopFront removes and returns the oldest value, r must not be empty.
+func (r *Ring[T]) PopFront() T {
+	var zero T
+	v := r.buf[r.head]
+	r.buf[r.head] = zero
+	r.head = (r.head + 1) % len(r.buf)
+	r.len--
+
+	return v
+}
+
+// PopBack removes and returns the newest value, r must not be empty.
+func (r *Ring[T]) PopBack() T {
+	var zero T
+	i := (r.head + r.len - 1) % len(r.buf)
+	v := r.buf[i]
+	r.buf[i] = zero
+	r.len--
+
+	return v
+}
+
+// grow doubles the storage, bounded by the capacity
+func (r *Ring[T]) grow() {
+	size := 2 * len(r.buf)
+	if size > r.capacity {
+		size = r.capacity
+	}
+
+	buf := make([]T, size)
+	for i := 0; i < r.len; i++ {
+		buf[i] = r.At(i)
+	}
+	r.buf, r.head = buf, 0
+}
+
+// Reset removes all values.
+func (r *Ring[T]) Reset() {
+	var zero T
+	for i := range r.buf {
+		r.buf[i] = zero
+	}
+	r.head, r.len = 0, 0
+}
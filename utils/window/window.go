@@ -0,0 +1,140 @@
+package window
+
+import "sort"
+
+// Window is a ring of time ordered samples answering min, max and first queries
+// over a time range with monotonic deques, in amortized O(1) per sample.
+type Window[T any] struct {
+	samples *Ring[T]
+	base    int // sequence number of the oldest sample
+	start   int // sequence number of the first sample of the current range
+	mins    *Ring[int]
+	maxs    *Ring[int]
+
+	timeOf func(T) int64
+	lowOf  func(T) float64
+	highOf func(T) float64
+}
+
+// New returns an empty window holding at most capacity samples.
+// timeOf gives the sample time, samples must be pushed in time order,
+// lowOf the value compared for min and highOf the value compared for max.
+func New[T any](capacity int, timeOf func(T) int64, lowOf func(T) float64, highOf func(T) float64) *Window[T] {
+	return &Window[T]{
+		samples: NewRing[T](capacity),
+		mins:    NewRing[int](capacity),
+		maxs:    NewRing[int](capacity),
+		timeOf:  timeOf,
+		lowOf:   lowOf,
+		highOf:  highOf,
+	}
+}
+
+// Len returns the number of samples of window w.
+func (w *Window[T]) Len() int { return w.samples.Len() }
+
+// At returns the i-th sample, 0 being the oldest one.
+func (w *Window[T]) At(i int) T { return w.samples.At(i) }
+
+// Front returns the oldest sample or the zero value if w is empty.
+func (w *Window[T]) Front() T { return w.samples.Front() }
+
+// Back returns the newest sample or the zero value if w is empty.
+func (w *Window[T]) Back() T { return w.samples.Back() }
+
+// Push appends sample v, evicting the oldest one if w is full.
+func (w *Window[T]) Push(v T) {
+	if _, evicted := w.samples.PushBack(v); evicted {
+		w.base++
+		if w.start < w.base {
+			w.start = w.base
+		}
+		w.evict()
+	}
+
+	w.insert(w.base+w.samples.Len()-1, v)
+}
+
+// Evict removes the samples with a time before t.
+func (w *Window[T]) Evict(t int64) {
+	for w.samples.Len() > 0 && w.timeOf(w.samples.Front()) < t {
+		w.samples.PopFront()
+		w.base++
+	}
+
+	if w.start < w.base {
+		w.start = w.base
+	}
+	w.evict()
+}
+
+// Range returns the min, max and first samples with a time not before from.
+// ok is false if there is no such sample.
+func (w *Window[T]) Range(from int64) (min T, max T, first T, ok bool) {
+	end := w.base + w.samples.Len()
+	if w.start > w.base && w.timeOf(w.at(w.start-1)) >= from {
+		w.rebuild(from)
+	} else {
+		for w.start < end && w.timeOf(w.at(w.start)) < from {
+			w.start++
+		}
+		w.evict()
+	}
+
+	if w.start == end {
+		return min, max, first, false
+	}
+
+	return w.at(w.mins.Front()), w.at(w.maxs.Front()), w.at(w.start), true
+}
+
+// Search returns the index of the oldest sample with a time not before from,
+// Len if there is none.
+func (w *Window[T]) Search(from int64) int {
+	return sort.Search(w.samples.Len(), func(i int) bool { return w.timeOf(w.samples.At(i)) >= from })
+}
+
+// Reset removes all samples.
+func (w *Window[T]) Reset() {
+	w.samples.Reset()
+	w.mins.Reset()
+	w.maxs.Reset()
+	w.base, w.start = 0, 0
+}
+
+func (w *Window[T]) at(seq int) T { return w.samples.At(seq - w.base) }
+
+// insert sample v with sequence number seq into the deques
+func (w *Window[T]) insert(seq int, v T) {
+	low, high := w.lowOf(v), w.highOf(v)
+	for w.mins.Len() > 0 && w.lowOf(w.at(w.mins.Back())) >= low {
+		w.mins.PopBack()
+	}
+	w.mins.PushBack(seq)
+
+	for w.maxs.Len() > 0 && w.highOf(w.at(w.maxs.Back())) <= high {
+		w.maxs.PopBack()
+	}
+	w.maxs.PushBack(seq)
+}
+
+// evict deque entries before the current range
+func (w *Window[T]) evict() {
+	for w.mins.Len() > 0 && w.mins.Front() < w.start {
+		w.mins.PopFront()
+	}
+	for w.maxs.Len() > 0 && w.maxs.Front() < w.start {
+		w.maxs.PopFront()
+	}
+}
+
+// rebuild the deques when the range start moves backward
+func (w *Window[T]) rebuild(from int64) {
+	w.start = w.base + w.Search(from)
+	w.mins.Reset()
+	w.maxs.Reset()
+
+	for seq := w.start; seq < w.base+w.samples.Len(); seq++ {
+		w.insert(seq, w.at(seq))
+	}
+}
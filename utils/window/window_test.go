@@ -0,0 +1,258 @@
+package window
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"alertbot/utils/list"
+)
+
+// The benchmarks replay one all-markets ticker event: about 2,400 symbols pushing
+// a sample into a 3,600 samples history and querying a 2 minutes window.
+const (
+	benchSymbols  = 2400
+	benchCapacity = 60 * 60
+	benchWindow   = 2 * 60 * 1000
+)
+
+type sample struct {
+	Price       float64
+	BaseVolume  float64
+	QuoteVolume float64
+	Time        int64
+}
+
+func benchPrice(symbol int, t int64) float64 {
+	return 100 + math.Sin(float64(t)/float64(60_000)+float64(symbol))
+}
+
+func BenchmarkListMinAndMax(b *testing.B) {
+	compare := func(t int64) func(a *list.Element, b *list.Element) int {
+		return func(a *list.Element, b *list.Element) int {
+			if a.Value.(*sample).Price*b.Value.(*sample).Price == 0 {
+				return -1
+			}
+			if a.Value.(*sample).Time < t {
+				return -1
+			}
+			if a.Value.(*sample).Price >= b.Value.(*sample).Price {
+				return 1
+			}
+			return 0
+		}
+	}
+
+	histories := make([]*list.List, benchSymbols)
+	for s := range histories {
+		histories[s] = list.NewList(benchCapacity, &sample{})
+		for t := int64(0); t < benchCapacity; t++ {
+			histories[s].Push(&sample{Price: benchPrice(s, t*1000), Time: t * 1000})
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t := int64(benchCapacity+i) * 1000
+		for s, history := range histories {
+			history.Push(&sample{Price: benchPrice(s, t), Time: t})
+			history.MinAndMax(compare(t - benchWindow))
+		}
+	}
+}
+
+func BenchmarkWindowRange(b *testing.B) {
+	timeOf := func(v sample) int64 { return v.Time }
+	valueOf := func(v sample) float64 { return v.Price }
+
+	histories := make([]*Window[sample], benchSymbols)
+	for s := range histories {
+		histories[s] = New(benchCapacity, timeOf, valueOf, valueOf)
+		for t := int64(0); t < benchCapacity; t++ {
+			histories[s].Push(sample{Price: benchPrice(s, t*1000), Time: t * 1000})
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t := int64(benchCapacity+i) * 1000
+		for s, history := range histories {
+			history.Range(t - benchWindow)
+			history.Push(sample{Price: benchPrice(s, t), Time: t})
+		}
+	}
+}
+
+type point struct {
+	Time int64
+	Low  float64
+	High float64
+}
+
+// brute force model of a window, a bounded slice scanned on every query
+type model struct {
+	capacity int
+	points   []point
+}
+
+func (m *model) push(p point) {
+	m.points = append(m.points, p)
+	if len(m.points) > m.capacity {
+		m.points = m.points[1:]
+	}
+}
+
+func (m *model) evict(t int64) {
+	for len(m.points) > 0 && m.points[0].Time < t {
+		m.points = m.points[1:]
+	}
+}
+
+func (m *model) rangeFrom(from int64) (low float64, high float64, first int64, ok bool) {
+	for _, p := range m.points {
+		if p.Time < from {
+			continue
+		}
+		if !ok {
+			low, high, first, ok = p.Low, p.High, p.Time, true
+			continue
+		}
+		low, high = math.Min(low, p.Low), math.Max(high, p.High)
+	}
+	return low, high, first, ok
+}
+
+func TestWindowRangeAgainstBruteForce(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity int
+		values   int // distinct values, few of them make ties
+	}{
+		{"small", 5, 100},
+		{"grows and wraps", 200, 1000},
+		{"ties", 50, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(1))
+			w := New(tt.capacity, func(p point) int64 { return p.Time },
+				func(p point) float64 { return p.Low }, func(p point) float64 { return p.High })
+			m := &model{capacity: tt.capacity}
+
+			now := int64(0)
+			for i := 0; i < 20_000; i++ {
+				switch op := rnd.Intn(10); {
+				case op < 6:
+					now += 1 + rnd.Int63n(3)
+					low := float64(rnd.Intn(tt.values))
+					p := point{Time: now, Low: low, High: low + float64(rnd.Intn(tt.values))}
+					w.Push(p)
+					m.push(p)
+				case op < 7:
+					// evict rarely and not far to keep the window filled
+					before := now - int64(tt.capacity) - rnd.Int63n(int64(tt.capacity))
+					w.Evict(before)
+					m.evict(before)
+				default:
+					// ranges move forward mostly and backward sometimes
+					from := now - rnd.Int63n(int64(3*tt.capacity))
+					min, max, first, ok := w.Range(from)
+					low, high, firstTime, want := m.rangeFrom(from)
+					if ok != want {
+						t.Fatalf("step %d: Range(%d) ok = %v, want %v", i, from, ok, want)
+					}
+					if ok && (min.Low != low || max.High != high || first.Time != firstTime) {
+						t.Fatalf("step %d: Range(%d) = %v %v %v, want low %v high %v first %d",
+							i, from, min, max, first, low, high, firstTime)
+					}
+				}
+
+				if w.Len() != len(m.points) {
+					t.Fatalf("step %d: Len = %d, want %d", i, w.Len(), len(m.points))
+				}
+			}
+		})
+	}
+}
+
+func TestWindowSearch(t *testing.T) {
+	w := New(10, func(p point) int64 { return p.Time },
+		func(p point) float64 { return p.Low }, func(p point) float64 { return p.High })
+	for i := int64(1); i <= 15; i++ {
+		w.Push(point{Time: i * 10})
+	}
+
+	// the oldest samples 10..50 were evicted by the capacity
+	tests := []struct {
+		from int64
+		want int
+	}{
+		{0, 0},
+		{60, 0},
+		{61, 1},
+		{100, 4},
+		{150, 9},
+		{151, 10},
+	}
+	for _, tt := range tests {
+		if got := w.Search(tt.from); got != tt.want {
+			t.Errorf("Search(%d) = %d, want %d", tt.from, got, tt.want)
+		}
+	}
+
+	w.Reset()
+	if _, _, _, ok := w.Range(0); ok || w.Len() != 0 {
+		t.Errorf("Range after Reset ok = %v, Len = %d", ok, w.Len())
+	}
+}
+
+func TestRingAgainstSlice(t *testing.T) {
+	for _, capacity := range []int{1, 3, initialCapacity, 150} {
+		rnd := rand.New(rand.NewSource(int64(capacity)))
+		r := NewRing[int](capacity)
+		values := []int{}
+
+		for i := 0; i < 5_000; i++ {
+			switch op := rnd.Intn(10); {
+			case op < 6:
+				evicted, full := r.PushBack(i)
+				if full != (len(values) == capacity) {
+					t.Fatalf("capacity %d step %d: PushBack full = %v with %d values", capacity, i, full, len(values))
+				}
+				if full {
+					if evicted != values[0] {
+						t.Fatalf("capacity %d step %d: evicted %d, want %d", capacity, i, evicted, values[0])
+					}
+					values = values[1:]
+				}
+				values = append(values, i)
+			case op < 8 && len(values) > 0:
+				if v := r.PopFront(); v != values[0] {
+					t.Fatalf("capacity %d step %d: PopFront = %d, want %d", capacity, i, v, values[0])
+				}
+				values = values[1:]
+			case len(values) > 0:
+				if v := r.PopBack(); v != values[len(values)-1] {
+					t.Fatalf("capacity %d step %d: PopBack = %d, want %d", capacity, i, v, values[len(values)-1])
+				}
+				values = values[:len(values)-1]
+			}
+
+			if r.Len() != len(values) {
+				t.Fatalf("capacity %d step %d: Len = %d, want %d", capacity, i, r.Len(), len(values))
+			}
+			for j, v := range values {
+				if r.At(j) != v {
+					t.Fatalf("capacity %d step %d: At(%d) = %d, want %d", capacity, i, j, r.At(j), v)
+				}
+			}
+		}
+
+		if r.Cap() != capacity {
+			t.Errorf("Cap = %d, want %d", r.Cap(), capacity)
+		}
+	}
+}
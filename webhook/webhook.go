@@ -0,0 +1,455 @@
+// Package webhook forwards alerts as signed JSON payloads with a persistent retry queue.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"alertbot/record"
+)
+
+const (
+	maxAttempts = 20
+	maxBackoff  = 10 * time.Minute
+	queueSize   = 1024 // payloads waiting to be persisted
+)
+
+var (
+	routesBucket = []byte("routes")
+	queueBucket  = []byte("queue")
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the body when the route has a secret
+const SignatureHeader = "X-Alertbot-Signature"
+
+// Payload is the JSON body posted for an alert or a message
+type Payload struct {
+	Type    string             `json:"type"`
+	Symbol  string             `json:"symbol,omitempty"`
+	Market  string             `json:"market,omitempty"`
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+	Text    string             `json:"text"`
+	Time    int64              `json:"time"`
+}
+
+// NewPayload of an alert, a nil alert for a plain message of channel
+func NewPayload(channel string, a *record.Alert, text string) Payload {
+	if a == nil {
+		return Payload{Type: channel, Text: text, Time: time.Now().UnixMilli()}
+	}
+
+	metrics := map[string]float64{
+		"price": a.Price,
+		"rate":  a.Rate,
+		"value": a.Value,
+	}
+	if a.VolumeRate != 0 {
+		metrics["volume_rate"] = a.VolumeRate
+	}
+	if a.Quantity != 0 {
+		metrics["quantity"] = a.Quantity
+	}
+	if a.Number != 0 {
+		metrics["number"] = float64(a.Number)
+	}
+
+	return Payload{Type: a.Channel, Symbol: a.Symbol, Market: a.Market, Metrics: metrics, Text: text, Time: a.Time}
+}
+
+// Route sends the payloads of a channel to a URL, ALL matches every channel but SYSTEM
+type Route struct {
+	ID      uint64
+	Channel string
+	URL     string
+	Secret  string `json:",omitempty"`
+}
+
+func (r Route) match(channel string) bool {
+	return r.Channel == channel || (r.Channel == "ALL" && channel != "SYSTEM")
+}
+
+func (r Route) String() string {
+	signed := ""
+	if r.Secret != "" {
+		signed = " signed"
+	}
+	return fmt.Sprintf("#%d %s -> %s%s", r.ID, r.Channel, r.URL, signed)
+}
+
+type delivery struct {
+	RouteID  uint64
+	Body     []byte
+	Attempts int
+	NextTry  int64
+}
+
+// queued payload for routes, waiting to be persisted
+type queued struct {
+	routes []Route
+	body   []byte
+}
+
+// Sink delivers payloads to the routes, retrying with exponential backoff
+type Sink struct {
+	db      *bolt.DB
+	client  *http.Client
+	wake    chan struct{}
+	queue   chan queued
+	written chan struct{} // closed when the queue is persisted after Close
+
+	mu     sync.RWMutex
+	routes map[uint64]Route
+	closed bool
+}
+
+// Open create or open the sink with its routes and queue at path
+func Open(path string) (*Sink, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Sink{
+		db:      db,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		wake:    make(chan struct{}, 1),
+		queue:   make(chan queued, queueSize),
+		written: make(chan struct{}),
+		routes:  make(map[uint64]Route),
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{routesBucket, queueBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		return tx.Bucket(routesBucket).ForEach(func(k, v []byte) error {
+			var r Route
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			s.routes[r.ID] = r
+			return nil
+		})
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	go s.write()
+
+	return s, nil
+}
+
+// Close the sink once the posted payloads are persisted
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	<-s.written
+	return s.db.Close()
+}
+
+// AddRoute for a channel
+func (s *Sink) AddRoute(channel string, url string, secret string) (Route, error) {
+	r := Route{Channel: strings.ToUpper(channel), URL: url, Secret: secret}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(routesBucket)
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		r.ID = id
+
+		v, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		return b.Put(key(id), v)
+	})
+	if err != nil {
+		return Route{}, err
+	}
+
+	s.mu.Lock()
+	s.routes[r.ID] = r
+	s.mu.Unlock()
+
+	return r, nil
+}
+
+// RemoveRoute by ID, its queued deliveries are dropped on their next try
+func (s *Sink) RemoveRoute(id uint64) (bool, error) {
+	s.mu.Lock()
+	_, found := s.routes[id]
+	delete(s.routes, id)
+	s.mu.Unlock()
+
+	if !found {
+		return false, nil
+	}
+
+	return true, s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(routesBucket).Delete(key(id))
+	})
+}
+
+// Routes ordered by ID
+func (s *Sink) Routes() []Route {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ret := make([]Route, 0, len(s.routes))
+	for _, r := range s.routes {
+		ret = append(ret, r)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+
+	return ret
+}
+
+// Post queue the payload for the routes of channel, persisted in the background
+// so that the alerts do not wait for the disk, dropped when the queue is full
+func (s *Sink) Post(channel string, p Payload) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return
+	}
+
+	routes := []Route{}
+	for _, r := range s.routes {
+		if r.match(channel) {
+			routes = append(routes, r)
+		}
+	}
+
+	if len(routes) == 0 {
+		return
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(p); err != nil {
+		log.Println(err)
+		return
+	}
+
+	select {
+	case s.queue <- queued{routes: routes, body: buf.Bytes()}:
+	default:
+		log.Printf("Webhook queue full, %s dropped\n", p.Type)
+	}
+}
+
+// write the posted payloads to the queue, the ones waiting together, until Close
+func (s *Sink) write() {
+	defer close(s.written)
+
+	for q := range s.queue {
+		batch := []queued{q}
+	drain:
+		for len(batch) < queueSize {
+			select {
+			case q, ok := <-s.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, q)
+			default:
+				break drain
+			}
+		}
+
+		if err := s.db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(queueBucket)
+			for _, q := range batch {
+				for _, r := range q.routes {
+					id, err := b.NextSequence()
+					if err != nil {
+						return err
+					}
+
+					v, err := json.Marshal(delivery{RouteID: r.ID, Body: q.body})
+					if err != nil {
+						return err
+					}
+
+					if err := b.Put(key(id), v); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}); err != nil {
+			log.Println(err)
+			continue
+		}
+
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Start delivering the queue, it never returns
+func (s *Sink) Start() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.wake:
+		}
+		s.deliver()
+	}
+}
+
+// entry of the queue
+type entry struct {
+	key []byte
+	d   delivery
+}
+
+// deliver the due deliveries, each route on its own in queue order
+func (s *Sink) deliver() {
+	byRoute := map[uint64][]entry{}
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(queueBucket).ForEach(func(k, v []byte) error {
+			var d delivery
+			if err := json.Unmarshal(v, &d); err != nil {
+				return err
+			}
+			byRoute[d.RouteID] = append(byRoute[d.RouteID], entry{key: append([]byte{}, k...), d: d})
+			return nil
+		})
+	}); err != nil {
+		log.Println(err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for id, entries := range byRoute {
+		wg.Add(1)
+		go func(id uint64, entries []entry) {
+			defer wg.Done()
+			s.deliverRoute(id, entries)
+		}(id, entries)
+	}
+	wg.Wait()
+}
+
+// deliverRoute send the entries of a route until one is not due or fails,
+// the later ones wait behind it
+func (s *Sink) deliverRoute(id uint64, entries []entry) {
+	s.mu.RLock()
+	r, found := s.routes[id]
+	s.mu.RUnlock()
+
+	for _, e := range entries {
+		if found && e.d.NextTry > time.Now().UnixMilli() {
+			return
+		}
+
+		done := !found
+		if found {
+			err := s.send(r, e.d.Body)
+			done = err == nil
+			if err != nil {
+				e.d.Attempts++
+				if e.d.Attempts >= maxAttempts {
+					log.Printf("Webhook %d dropped after %d attempts: %v\n", r.ID, e.d.Attempts, err)
+					done = true
+				} else {
+					e.d.NextTry = time.Now().Add(backoff(e.d.Attempts)).UnixMilli()
+				}
+			}
+		}
+
+		if err := s.db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(queueBucket)
+			if done {
+				return b.Delete(e.key)
+			}
+
+			v, err := json.Marshal(e.d)
+			if err != nil {
+				return err
+			}
+			return b.Put(e.key, v)
+		}); err != nil {
+			log.Println(err)
+		}
+
+		if !done {
+			return
+		}
+	}
+}
+
+func (s *Sink) send(r Route, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(r.Secret, body))
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("status %s", res.Status)
+	}
+
+	return nil
+}
+
+// Sign body with secret as hex HMAC-SHA256
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func backoff(attempts int) time.Duration {
+	d := time.Second << uint(attempts)
+	if d <= 0 || d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+func key(id uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, id)
+	return k
+}
@@ -0,0 +1,219 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// receiver records the requests of a webhook endpoint, failing the first ones
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	rc.bodies, rc.headers = append(rc.bodies, body), append(rc.headers, r.Header)
+	if rc.failures > 0 {
+		rc.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (rc *receiver) received() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return len(rc.bodies)
+}
+
+func serve(t *testing.T, rc *receiver) string {
+	server := httptest.NewServer(rc)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// queue of the sink, once the posted payloads are persisted
+func queue(t *testing.T, s *Sink, want int) []delivery {
+	deadline := time.Now().Add(time.Second)
+	for {
+		ret := []delivery{}
+		if err := s.db.View(func(tx *bolt.Tx) error {
+			return tx.Bucket(queueBucket).ForEach(func(k, v []byte) error {
+				var d delivery
+				if err := json.Unmarshal(v, &d); err != nil {
+					return err
+				}
+				ret = append(ret, d)
+				return nil
+			})
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(ret) >= want || time.Now().After(deadline) {
+			return ret
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// retryNow make the queued deliveries due
+func retryNow(t *testing.T, s *Sink) {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(queueBucket)
+		return b.ForEach(func(k, v []byte) error {
+			var d delivery
+			if err := json.Unmarshal(v, &d); err != nil {
+				return err
+			}
+			d.NextTry = 0
+			v, err := json.Marshal(d)
+			if err != nil {
+				return err
+			}
+			return b.Put(k, v)
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func openSink(t *testing.T, path string) *Sink {
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSigning(t *testing.T) {
+	rc := &receiver{}
+	s := openSink(t, filepath.Join(t.TempDir(), "webhook.db"))
+	defer s.Close()
+	s.AddRoute("up", serve(t, rc), "secret")
+
+	s.Post("UP", Payload{Type: "UP", Symbol: "SOLUSDT", Text: "<b>#UP</b> SOL", Time: 1})
+	s.Post("DOWN", Payload{Type: "DOWN", Symbol: "SOLUSDT"}) // no route
+	queue(t, s, 1)
+	s.deliver()
+
+	if rc.received() != 1 {
+		t.Fatalf("%d requests, want 1", rc.received())
+	}
+	if got, want := rc.headers[0].Get(SignatureHeader), Sign("secret", rc.bodies[0]); got != want {
+		t.Errorf("signature %q, want %q", got, want)
+	}
+
+	var p Payload
+	if err := json.Unmarshal(rc.bodies[0], &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Symbol != "SOLUSDT" || p.Text != "<b>#UP</b> SOL" {
+		t.Errorf("payload = %+v", p)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	failing, ok := &receiver{failures: 1000}, &receiver{}
+	s := openSink(t, filepath.Join(t.TempDir(), "webhook.db"))
+	defer s.Close()
+	s.AddRoute("ALL", serve(t, failing), "")
+	s.AddRoute("ALL", serve(t, ok), "")
+
+	for i := 0; i < 3; i++ {
+		s.Post("UP", Payload{Type: "UP", Time: int64(i)})
+	}
+	queue(t, s, 6)
+	s.deliver()
+
+	// the failing route is tried once a pass, the other one gets everything
+	if failing.received() != 1 || ok.received() != 3 {
+		t.Fatalf("requests %d failing %d ok, want 1 and 3", failing.received(), ok.received())
+	}
+	left := queue(t, s, 3)
+	if len(left) != 3 || left[0].Attempts != 1 || left[0].NextTry <= time.Now().UnixMilli() {
+		t.Fatalf("queue = %+v, want the 3 deliveries of the failing route, the first backing off", left)
+	}
+
+	// not due yet
+	s.deliver()
+	if failing.received() != 1 {
+		t.Errorf("%d requests before the backoff, want 1", failing.received())
+	}
+
+	// the route recovers, in queue order
+	failing.mu.Lock()
+	failing.failures = 0
+	failing.mu.Unlock()
+	retryNow(t, s)
+	s.deliver()
+	if failing.received() != 4 || len(queue(t, s, 0)) != 0 {
+		t.Fatalf("%d requests, queue %v, want all delivered", failing.received(), queue(t, s, 0))
+	}
+	for i, body := range failing.bodies[1:] {
+		var p Payload
+		json.Unmarshal(body, &p)
+		if p.Time != int64(i) {
+			t.Errorf("delivery %d is payload %d", i, p.Time)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 2 * time.Second},
+		{5, 32 * time.Second},
+		{10, maxBackoff},
+		{100, maxBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	rc := &receiver{}
+	path := filepath.Join(t.TempDir(), "webhook.db")
+	s := openSink(t, path)
+	r, _ := s.AddRoute("up", serve(t, rc), "secret")
+	s.Post("UP", Payload{Type: "UP"})
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s.Post("UP", Payload{Type: "UP"}) // closed, ignored
+
+	// the route and the queue survive a restart
+	s = openSink(t, path)
+	defer s.Close()
+	if routes := s.Routes(); len(routes) != 1 || routes[0] != r {
+		t.Fatalf("routes = %v, want %v", routes, r)
+	}
+	if len(queue(t, s, 1)) != 1 {
+		t.Fatal("queued delivery lost")
+	}
+
+	s.deliver()
+	if rc.received() != 1 || len(queue(t, s, 0)) != 0 {
+		t.Errorf("%d requests after restart, want 1", rc.received())
+	}
+}